@@ -0,0 +1,103 @@
+package sstable
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAlignment(t *testing.T) {
+	entries := generateEntries(20)
+	for _, opts := range []BuilderOptions{
+		{ValueAlignment: 4096, AlignmentThreshold: 100},
+		{ValueAlignment: 512, Checksums: true},
+		{ValueAlignment: 4096, AlignmentThreshold: 100, Dedup: true},
+		{ValueAlignment: 4096, KeyProvider: &testKeyProvider{mask: 1}},
+	} {
+		buf := buildTableWithOptions(t, entries, opts)
+		table, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{KeyProvider: opts.KeyProvider})
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		}
+		checkTable(t, table, entries)
+
+		for k, p := range entries {
+			r, err := table.GetReader([]byte(k))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uint64(len(p.val)) >= opts.AlignmentThreshold && len(p.val) > 0 &&
+				r.Offset()%int64(opts.ValueAlignment) != 0 {
+				t.Errorf("Value for key %s at unaligned offset %d", k, r.Offset())
+			}
+		}
+
+		stats := table.Stats()
+		if stats.PaddingSize <= 0 {
+			t.Error("Unexpected padding size", stats.PaddingSize)
+		}
+		report, err := table.Verify(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		} else if !report.OK() {
+			t.Error("Unexpected problems", report.Problems)
+		}
+	}
+}
+
+func TestAlignment_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic")
+		}
+	}()
+	NewBuilderWithOptions(io.Discard, nil, BuilderOptions{ValueAlignment: 1000})
+}
+
+func TestDirectFile(t *testing.T) {
+	entries := generateEntries(20)
+	path := filepath.Join(t.TempDir(), "table.sst")
+	buf := buildTableWithOptions(t, entries, BuilderOptions{ValueAlignment: DirectIOAlignment})
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenDirect(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Log("Direct I/O:", f.Direct())
+
+	table, err := Load(f)
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	checkTable(t, table, entries)
+
+	// Unaligned reads, and reads past the end of the file.
+	for _, c := range []struct {
+		off, length int
+	}{
+		{1, 10}, {4000, 200}, {len(buf) - 5, 10}, {len(buf) + 10, 10}, {0, len(buf)},
+	} {
+		p := make([]byte, c.length)
+		n, err := f.ReadAt(p, int64(c.off))
+		expected := []byte{}
+		if c.off < len(buf) {
+			expected = buf[c.off:]
+		}
+		if len(expected) > c.length {
+			expected = expected[:c.length]
+		}
+		if !bytes.Equal(p[:n], expected) {
+			t.Errorf("ReadAt(%d, %d) returned wrong data", c.off, c.length)
+		}
+		if n < c.length && err != io.EOF {
+			t.Errorf("ReadAt(%d, %d) = %d, %v", c.off, c.length, n, err)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package sstable
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"log"
 
@@ -15,7 +18,13 @@ import (
 
 type keyLengthPair struct {
 	key    []byte
-	length uint32
+	length uint64
+	offset uint64
+
+	// Only kept until the index is encoded, when deduplicating.
+	extra []byte
+	// 1 + index of the entry whose value this entry shares, or 0.
+	sameAs int
 }
 
 type Builder struct {
@@ -26,79 +35,483 @@ type Builder struct {
 	keys     []keyLengthPair
 	valuePos uint64
 
+	maxKeyLength    int
+	restartInterval int
+	restarts        []uint64
+	checksums       bool
+	dedup           bool
+	alignment       uint64
+	alignThreshold  uint64
+	padding         uint64
+	keyProvider     KeyProvider
+	dataKey         []byte
+	cipher          *tableCipher
+	// Set when building a key set (see NewSetBuilder).
+	keySet bool
+
+	// Estimated index and data size of entries which haven't been encoded yet,
+	// when deduplicating.
+	pendingSize uint64
+
 	started bool
 	prev    []byte
+	// Set once the table is built or aborted.
+	done bool
+
+	// Set if building a file (see NewFileBuilder).
+	file *fileTarget
 }
 
 type ValueWriter func(key []byte, w io.Writer) (int, error)
 
 const (
-	MaxKeyLength   = 256
-	MaxValueLength = 1024 * 1024 * 1024 // 1GiB
+	// Default maximum key length.
+	MaxKeyLength = 256
+	// Largest maximum key length that can be configured.
+	MaxKeyLengthLimit = 64 * 1024
+	MaxValueLength    = 1024 * 1024 * 1024 * 1024 // 1TiB
+
+	DefaultIndexRestartInterval = 1024
 )
 
+type BuilderOptions struct {
+	// Maximum key length, up to MaxKeyLengthLimit. If 0, MaxKeyLength is used.
+	MaxKeyLength int
+
+	// Number of index entries between restart points, which allow the index to
+	// be decoded in parallel. If 0, DefaultIndexRestartInterval is used.
+	IndexRestartInterval int
+
+	// Store a CRC-32C checksum of each value.
+	Checksums bool
+
+	// If non-zero, values are placed at offsets (from the start of the file)
+	// which are a multiple of this, allowing them to be read with direct I/O
+	// (see OpenDirect) or page-aligned mmap. Must be a power of 2.
+	ValueAlignment int
+
+	// Only values at least this long are aligned.
+	AlignmentThreshold uint64
+
+	// Store identical values once, with their index entries sharing the same
+	// offset. Build calls the ValueWriter for every value to hash it, and then
+	// again for each distinct value, so it must write the same data each time.
+	Dedup bool
+
+	// If set, the index and values are encrypted with AES-GCM using a new data
+	// key, which is wrapped by the key provider. Encrypted values are already
+	// authenticated, so this can't be combined with Checksums.
+	KeyProvider KeyProvider
+
+	// Size of the chunks values are split into for encryption. Reading any part
+	// of a value requires reading and decrypting whole chunks. If 0,
+	// DefaultEncryptionChunkSize is used.
+	EncryptionChunkSize int
+}
+
 func NewBuilder(w io.Writer, vf ValueWriter) *Builder {
-	return &Builder{w: w, vf: vf}
+	return NewBuilderWithOptions(w, vf, BuilderOptions{})
+}
+
+func NewBuilderWithOptions(w io.Writer, vf ValueWriter, opts BuilderOptions) *Builder {
+	b := &Builder{
+		w:               w,
+		vf:              vf,
+		maxKeyLength:    MaxKeyLength,
+		restartInterval: DefaultIndexRestartInterval,
+		checksums:       opts.Checksums,
+		dedup:           opts.Dedup,
+		alignThreshold:  opts.AlignmentThreshold,
+		keyProvider:     opts.KeyProvider,
+	}
+	if err := opts.validate(); err != nil {
+		log.Panicln(err)
+	}
+	if opts.MaxKeyLength > 0 {
+		b.maxKeyLength = opts.MaxKeyLength
+	}
+	if opts.IndexRestartInterval > 0 {
+		b.restartInterval = opts.IndexRestartInterval
+	}
+	b.alignment = uint64(opts.ValueAlignment)
+	if b.keyProvider != nil {
+		chunkSize := opts.EncryptionChunkSize
+		if chunkSize == 0 {
+			chunkSize = DefaultEncryptionChunkSize
+		}
+		b.dataKey, b.cipher = newDataKey(chunkSize)
+	}
+	return b
+}
+
+// Returns an error if the options are invalid.
+func (opts *BuilderOptions) validate() error {
+	if opts.MaxKeyLength > MaxKeyLengthLimit || opts.MaxKeyLength < 0 {
+		return fmt.Errorf("Invalid max key length %d", opts.MaxKeyLength)
+	} else if opts.IndexRestartInterval < 0 {
+		return fmt.Errorf("Invalid index restart interval %d", opts.IndexRestartInterval)
+	} else if a := opts.ValueAlignment; a < 0 || a&(a-1) != 0 {
+		return fmt.Errorf("Invalid value alignment %d", a)
+	}
+	if opts.KeyProvider != nil {
+		if opts.Checksums {
+			return errors.New("Checksums can't be used with encryption")
+		} else if c := opts.EncryptionChunkSize; c < 0 || c > MaxEncryptionChunkSize {
+			return fmt.Errorf("Invalid encryption chunk size %d", c)
+		}
+	}
+	return nil
 }
 
-func (b *Builder) Add(key []byte, valueLength uint32, meta []byte) {
-	if !b.started {
+var ErrBuilderDone = errors.New("Builder already built or aborted")
+
+// Add adds an entry. Keys must be added in strictly increasing order. Returns
+// ErrBuilderDone if the table has already been built or aborted.
+func (b *Builder) Add(key []byte, valueLength uint64, meta []byte) error {
+	if b.done {
+		return ErrBuilderDone
+	} else if !b.started {
 		b.started = true
 	} else if bytes.Compare(b.prev, key) != -1 {
 		log.Panicf("Key %d is before previous %d", key, b.prev)
 	}
 
-	if len(key) > MaxKeyLength {
-		log.Panicf("Key length %d > 256", len(key))
+	if len(key) > b.maxKeyLength {
+		log.Panicf("Key length %d > %d", len(key), b.maxKeyLength)
 	} else if valueLength > MaxValueLength {
-		log.Panicf("Value length %d > 1GiB", valueLength)
+		log.Panicf("Value length %d > 1TiB", valueLength)
 	}
 
 	keyDup := dup(key)
+	if b.dedup {
+		// Entries are encoded by Build, once values have been hashed.
+		b.keys = append(b.keys, keyLengthPair{key: keyDup, length: valueLength, extra: dup(meta)})
+		b.pendingSize += b.entryEstimate(key, valueLength, meta)
+	} else {
+		offset := b.placeValue(valueLength)
+		b.encodeEntry(len(b.keys), b.prev, key, offset, valueLength, meta)
+		b.keys = append(b.keys, keyLengthPair{key: keyDup, length: valueLength, offset: offset})
+	}
 	b.prev = keyDup
+	return nil
+}
+
+// Returns the number of entries added.
+func (b *Builder) NumEntries() int {
+	return len(b.keys)
+}
+
+// Upper bound on the encoding overhead of an index entry, beyond its key and
+// extra data.
+const indexEntryOverhead = 32
+
+// EstimatedSize returns the approximate size of the table, if it were built
+// with the entries added so far. When deduplicating, duplicate values aren't
+// known until Build, so this is an upper bound.
+func (b *Builder) EstimatedSize() uint64 {
+	// Fixed header fields, and approximate encoding of restarts.
+	const headerOverhead = 64
+	size := 4 + headerOverhead + 10*uint64(len(b.restarts)) +
+		uint64(len(b.indexBuf.Bytes())) + b.valuePos + b.pendingSize
+	if b.cipher != nil {
+		// Wrapped data key and the index's authentication tag.
+		size += 4*dataKeySize + uint64(b.cipher.aead.Overhead())
+	}
+	if b.alignment > 0 {
+		size += b.alignment - 1
+	}
+	if b.checksums {
+		size += 4 * uint64(len(b.keys))
+	}
+	return size
+}
+
+// Upper bound on the index and data size of an entry, excluding its checksum.
+func (b *Builder) entryEstimate(key []byte, valueLength uint64, meta []byte) uint64 {
+	size := uint64(len(key)+len(meta)+indexEntryOverhead) + b.storedLength(valueLength)
+	if b.alignment > 0 && valueLength > 0 && valueLength >= b.alignThreshold {
+		size += b.alignment - 1
+	}
+	return size
+}
+
+// Returns what EstimatedSize would be after adding an entry.
+func (b *Builder) estimatedSizeWith(key []byte, valueLength uint64, meta []byte) uint64 {
+	size := b.EstimatedSize() + b.entryEstimate(key, valueLength, meta)
+	if len(b.keys)%b.restartInterval == 0 {
+		// New restart point.
+		size += 10
+	}
+	if b.checksums {
+		size += 4
+	}
+	return size
+}
+
+// Appends entry i to the index.
+func (b *Builder) encodeEntry(i int, prev, key []byte, offset, length uint64, meta []byte) {
+	shared := commonPrefix(prev, key)
+	if i%b.restartInterval == 0 {
+		b.restarts = append(b.restarts, uint64(len(b.indexBuf.Bytes())))
+		shared = 0
+	}
+
+	if b.keySet {
+		b.indexBuf.EncodeVarint(uint64(shared))
+		b.indexBuf.EncodeRawBytes(key[shared:])
+		return
+	}
 
 	var entry pb.IndexEntry
-	entry.Key = key
-	entry.Offset = b.valuePos
-	b.valuePos += uint64(valueLength)
-	entry.Length = valueLength
+	entry.Key = key[shared:]
+	entry.SharedPrefix = uint32(shared)
+	entry.Offset = offset
+	entry.Length = length
 	entry.Extra = meta
-	b.keys = append(b.keys, keyLengthPair{keyDup, valueLength})
 	if err := b.indexBuf.EncodeMessage(&entry); err != nil {
 		log.Panicln("Unexpected error encoding index", err)
 	}
 }
 
+// Allocates space for a value in the data section, aligning it if necessary,
+// and returns its offset.
+func (b *Builder) placeValue(length uint64) uint64 {
+	if b.alignment > 0 && length > 0 && length >= b.alignThreshold {
+		aligned := alignUp(b.valuePos, b.alignment)
+		b.padding += aligned - b.valuePos
+		b.valuePos = aligned
+	}
+	offset := b.valuePos
+	b.valuePos += b.storedLength(length)
+	return offset
+}
+
+func alignUp(n, alignment uint64) uint64 {
+	return (n + alignment - 1) &^ (alignment - 1)
+}
+
+// Returns the number of bytes a value occupies in the data section.
+func (b *Builder) storedLength(length uint64) uint64 {
+	if b.cipher != nil {
+		return b.cipher.storedLength(length)
+	}
+	return length
+}
+
+// Build writes the table. Returns ErrBuilderDone if the table has already been
+// built or aborted.
 func (b *Builder) Build() error {
+	if b.done {
+		return ErrBuilderDone
+	}
+	b.done = true
+	err := b.build()
+	if b.file != nil {
+		err = b.file.finish(err)
+		b.file = nil
+	}
+	return err
+}
+
+// Abort discards the added entries, releasing their memory. If building a
+// file, the temporary file is removed. The builder can't be used again until
+// Reset.
+func (b *Builder) Abort() {
+	b.done = true
+	b.keys = nil
+	b.indexBuf = proto.Buffer{}
+	b.restarts = nil
+	b.prev = nil
+	if b.file != nil {
+		b.file.abort()
+		b.file = nil
+	}
+}
+
+// Reset discards any added entries, and prepares the builder to build a new
+// table, written to w. Buffers are reused, which makes building many small
+// tables cheaper. If a file was being built and Build wasn't called, the
+// temporary file is removed.
+func (b *Builder) Reset(w io.Writer) {
+	if b.file != nil {
+		b.file.abort()
+		b.file = nil
+	}
+	b.w = w
+	for i := range b.keys {
+		b.keys[i] = keyLengthPair{}
+	}
+	b.keys = b.keys[:0]
+	b.indexBuf.Reset()
+	b.valuePos = 0
+	b.restarts = b.restarts[:0]
+	b.padding = 0
+	b.pendingSize = 0
+	b.started = false
+	b.prev = nil
+	b.done = false
+	if b.cipher != nil {
+		// Every table must have its own data key, since nonces are only unique
+		// within a table.
+		b.dataKey, b.cipher = newDataKey(int(b.cipher.chunkSize))
+	}
+}
+
+// Encodes the header and index, which are written before the data section,
+// and returns them along with the offset of the data section.
+func (b *Builder) encodeHeader() (headerBuf, index []byte, dataOffset uint64, err error) {
+	if b.dedup {
+		if err := b.dedupValues(); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
 	var header pb.TableHeader
-	header.Version = 1
-	header.IndexLength = uint32(len(b.indexBuf.Bytes()))
-	header.IndexEntries = uint32(len(b.keys))
+	index = b.indexBuf.Bytes()
+	if b.cipher != nil {
+		wrapped, err := b.keyProvider.WrapKey(b.dataKey)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		header.Encryption = &pb.Encryption{
+			Cipher:     pb.Encryption_AES_256_GCM,
+			WrappedKey: wrapped,
+			ChunkSize:  uint32(b.cipher.chunkSize),
+		}
+		if len(index) > 0 {
+			index = b.cipher.seal(nil, index, nonceDomainIndex, 0)
+		}
+	}
+	header.Version = 2
+	if b.keySet {
+		header.Version = 3
+		header.KeySet = true
+	}
+	header.IndexLength = uint64(len(index))
+	header.IndexEntries = uint64(len(b.keys))
+	if b.maxKeyLength != MaxKeyLength {
+		header.MaxKeyLength = uint32(b.maxKeyLength)
+	}
+	header.IndexRestarts = b.restarts
+	header.IndexRestartInterval = uint32(b.restartInterval)
+	header.DataLength = b.valuePos
+	header.ValueAlignment = uint32(b.alignment)
+	header.DataPadding = b.padding
+	if b.checksums {
+		header.ValueChecksum = pb.TableHeader_CRC32C
+	}
 	// TODO: Implement index compression.
 
-	headerBuf, err := proto.Marshal(&header)
+	headerBuf, err = proto.Marshal(&header)
 	if err != nil {
-		return err
+		return nil, nil, 0, err
+	}
+	dataOffset = 4 + uint64(len(headerBuf)+len(index))
+	if b.alignment > 0 {
+		dataOffset = alignUp(dataOffset, b.alignment)
 	}
+	return headerBuf, index, dataOffset, nil
+}
 
+// Writes the header and index, and any padding before the data section.
+func writeHeader(w io.Writer, headerBuf, index []byte, dataOffset uint64) error {
 	var headerSize [4]byte
 	binary.LittleEndian.PutUint32(headerSize[:], uint32(len(headerBuf)))
-	_, err = iou.WriteMany(b.w, headerSize[:], headerBuf, b.indexBuf.Bytes())
+	_, err := iou.WriteMany(w, headerSize[:], headerBuf, index)
+	if err != nil {
+		return err
+	}
+	return writeZeros(w, dataOffset-4-uint64(len(headerBuf)+len(index)))
+}
+
+func (b *Builder) build() error {
+	headerBuf, index, dataOffset, err := b.encodeHeader()
 	if err != nil {
 		return err
 	}
+	if err := writeHeader(b.w, headerBuf, index, dataOffset); err != nil {
+		return err
+	}
 
+	var checksums []byte
+	var cw *checksumWriter
+	var ew *encryptingWriter
+	w := b.w
+	if b.cipher != nil {
+		ew = newEncryptingWriter(b.w, b.cipher)
+		w = ew
+	} else if b.checksums {
+		checksums = make([]byte, 0, 4*len(b.keys))
+		cw = &checksumWriter{w: b.w}
+		w = cw
+	}
+	var pos uint64
 	for _, pair := range b.keys {
-		if pair.length == 0 {
+		if pair.sameAs > 0 {
+			if cw != nil {
+				j := 4 * (pair.sameAs - 1)
+				checksums = append(checksums, checksums[j:j+4]...)
+			}
 			continue
 		}
-		n, err := b.vf(pair.key, b.w)
-		if err != nil {
-			return err
-		} else if n != int(pair.length) {
-			log.Panicf("Unexpected value write length %d, expected %d", n, pair.length)
+		if cw != nil {
+			cw.crc = 0
+		}
+		if pair.length > 0 {
+			if err := writeZeros(b.w, pair.offset-pos); err != nil {
+				return err
+			}
+			pos = pair.offset + b.storedLength(pair.length)
+			if ew != nil {
+				ew.pos = pair.offset
+			}
+			n, err := b.vf(pair.key, w)
+			if err != nil {
+				return err
+			} else if uint64(n) != pair.length {
+				log.Panicf("Unexpected value write length %d, expected %d", n, pair.length)
+			}
+			if ew != nil {
+				if err := ew.flush(); err != nil {
+					return err
+				}
+			}
 		}
+		if cw != nil {
+			checksums = binary.LittleEndian.AppendUint32(checksums, cw.crc)
+		}
+	}
+
+	if cw != nil {
+		_, err = b.w.Write(checksums)
 	}
+	return err
+}
 
+var zeros [4096]byte
+
+func writeZeros(w io.Writer, n uint64) error {
+	for n > 0 {
+		chunk := uint64(len(zeros))
+		if n < chunk {
+			chunk = n
+		}
+		if _, err := w.Write(zeros[:chunk]); err != nil {
+			return err
+		}
+		n -= chunk
+	}
 	return nil
 }
+
+// Computes a CRC-32C of data written through it.
+type checksumWriter struct {
+	w   io.Writer
+	crc uint32
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.crc = crc32.Update(w.crc, crc32cTable, p[:n])
+	return n, err
+}
@@ -1,6 +1,12 @@
 package sstable
 
 import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -19,3 +25,214 @@ func TestBuilderKeyTooLong(t *testing.T) {
 	}()
 	buildTable(t, testValuesKeyTooLong)
 }
+
+func longKeyValues() map[string]testValuePair {
+	prefix := strings.Repeat("/very/long/path", 100)
+	return map[string]testValuePair{
+		prefix + "/a":   {"value1", nil},
+		prefix + "/b":   {"value2", []byte{1}},
+		prefix + "/b/c": {"", nil},
+		prefix + "/d":   {"value3", nil},
+		"short":         {"value4", nil},
+		prefix[:700]:    {"value5", nil},
+	}
+}
+
+func TestBuilderMaxKeyLength(t *testing.T) {
+	values := longKeyValues()
+	buf := buildTableWithOptions(t, values, BuilderOptions{MaxKeyLength: 4096})
+	table, err := buildReader(t, buf)
+	if err != nil {
+		t.Fatal("Error building table", err)
+	}
+	checkTable(t, table, values)
+
+	stats := table.Stats()
+	if stats.IndexSize >= int64(stats.KeysSize) {
+		t.Error("Expected prefix encoded index to be smaller than keys",
+			stats.IndexSize, stats.KeysSize)
+	}
+	if stats.IndexMemory >= int64(stats.KeysSize) {
+		t.Error("Expected loaded index to use less memory than keys",
+			stats.IndexMemory, stats.KeysSize)
+	}
+}
+
+func TestBuilderMaxKeyLengthTooLong(t *testing.T) {
+	defer func() {
+		x := recover()
+		if x == nil {
+			t.Error("Expected panic")
+		} else {
+			t.Log("Expected panic", x)
+		}
+	}()
+	buildTableWithOptions(t, longKeyValues(), BuilderOptions{MaxKeyLength: 1024})
+}
+
+func TestBuilderChecksums(t *testing.T) {
+	table, err := buildReader(t, buildTableWithOptions(t, testValues, BuilderOptions{Checksums: true}))
+	if err != nil {
+		t.Fatal("Error building table", err)
+	}
+	checkTable(t, table, testValues)
+
+	for k, p := range testValues {
+		r, err := table.GetReader([]byte(k))
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		sum, ok := r.Checksum()
+		if !ok {
+			t.Error("Expected checksum")
+		} else if expected := crc32.Checksum([]byte(p.val), crc32cTable); sum != expected {
+			t.Error("Incorrect checksum", expected, sum)
+		}
+	}
+}
+
+func TestBuilderDone(t *testing.T) {
+	entries := testValues
+	b := NewBuilder(io.Discard, testValueWriter(entries))
+	addTestEntries(t, b, entries)
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add([]byte("zzz"), 0, nil); err != ErrBuilderDone {
+		t.Error("Expected ErrBuilderDone, got", err)
+	}
+	if err := b.Build(); err != ErrBuilderDone {
+		t.Error("Expected ErrBuilderDone, got", err)
+	}
+
+	b = NewBuilder(io.Discard, testValueWriter(entries))
+	addTestEntries(t, b, entries)
+	b.Abort()
+	if err := b.Add([]byte("zzz"), 0, nil); err != ErrBuilderDone {
+		t.Error("Expected ErrBuilderDone, got", err)
+	}
+	if err := b.Build(); err != ErrBuilderDone {
+		t.Error("Expected ErrBuilderDone, got", err)
+	}
+}
+
+func TestBuilderReset(t *testing.T) {
+	for _, opts := range []BuilderOptions{
+		{},
+		{Checksums: true, Dedup: true},
+		{KeyProvider: &testKeyProvider{mask: 3}},
+	} {
+		// The tables have distinct keys, so share one ValueWriter.
+		all := generateEntries(100)
+		for k, p := range testValues {
+			all[k] = p
+		}
+		b := NewBuilderWithOptions(io.Discard, testValueWriter(all), opts)
+		for _, entries := range []map[string]testValuePair{testValues, generateEntries(100), emptyTable} {
+			buf := new(bytes.Buffer)
+			b.Reset(buf)
+			addTestEntries(t, b, entries)
+			if err := b.Build(); err != nil {
+				t.Fatal(err)
+			}
+			table, err := LoadWithOptions(bytes.NewReader(buf.Bytes()), LoadOptions{KeyProvider: opts.KeyProvider})
+			if err != nil {
+				t.Fatal("Error loading table", err)
+			}
+			checkTable(t, table, entries)
+		}
+
+		// Reset after abort.
+		entries := testValues
+		b.Abort()
+		buf := new(bytes.Buffer)
+		b.Reset(buf)
+		addTestEntries(t, b, entries)
+		if err := b.Build(); err != nil {
+			t.Fatal(err)
+		}
+		table, err := LoadWithOptions(bytes.NewReader(buf.Bytes()), LoadOptions{KeyProvider: opts.KeyProvider})
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		}
+		checkTable(t, table, entries)
+	}
+}
+
+func TestFileBuilder(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table.sst")
+	entries := testValues
+	b, err := NewFileBuilder(path, testValueWriter(entries), BuilderOptions{Checksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestEntries(t, b, entries)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Table file exists before Build", err)
+	}
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := buildReader(t, buf)
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	checkTable(t, table, entries)
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Error("Unexpected files", files)
+	}
+}
+
+func TestFileBuilderMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table.sst")
+	b, err := NewFileBuilder(path, testValueWriter(testValues), BuilderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestEntries(t, b, testValues)
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The table should have the same mode as a file created with os.Create.
+	f, err := os.Create(filepath.Join(dir, "reference"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	expected, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != expected.Mode() {
+		t.Errorf("Table mode %v, expected %v", fi.Mode(), expected.Mode())
+	}
+}
+
+func TestFileBuilderAbort(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table.sst")
+	entries := testValues
+	b, err := NewFileBuilder(path, testValueWriter(entries), BuilderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestEntries(t, b, entries)
+	b.Abort()
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Error("Unexpected files", files)
+	}
+	if err := b.Build(); err != ErrBuilderDone {
+		t.Error("Expected ErrBuilderDone, got", err)
+	}
+}
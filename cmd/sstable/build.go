@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	sstable "github.com/akmistry/simple-sstable"
+)
+
+type record struct {
+	key   []byte
+	value []byte
+	extra []byte
+}
+
+// Reads input records, in any order.
+type inputReader interface {
+	// Returns the next record, or io.EOF when done.
+	read() (record, error)
+}
+
+type delimitedReader struct {
+	r *csv.Reader
+	f *commandFlags
+}
+
+func newDelimitedReader(in io.Reader, comma rune, f *commandFlags) *delimitedReader {
+	r := csv.NewReader(in)
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	if comma == '\t' {
+		r.LazyQuotes = true
+	}
+	return &delimitedReader{r: r, f: f}
+}
+
+func (d *delimitedReader) read() (record, error) {
+	fields, err := d.r.Read()
+	if err != nil {
+		return record{}, err
+	}
+	// Records may span several lines, so report the line the record starts on.
+	line, _ := d.r.FieldPos(0)
+	if len(fields) != 2 && len(fields) != 3 {
+		return record{}, fmt.Errorf("Line %d: expected 2 or 3 fields, got %d", line, len(fields))
+	}
+	extra := ""
+	if len(fields) == 3 {
+		extra = fields[2]
+	}
+	r, err := d.f.decodeRecord(fields[0], fields[1], extra)
+	if err != nil {
+		return r, fmt.Errorf("Line %d: %v", line, err)
+	}
+	return r, nil
+}
+
+type jsonRecord struct {
+	Key   *string `json:"key"`
+	Value string  `json:"value"`
+	Extra string  `json:"extra"`
+}
+
+// Reads one JSON object per line, skipping blank lines.
+type jsonlReader struct {
+	r    *bufio.Reader
+	f    *commandFlags
+	line int
+}
+
+func (j *jsonlReader) read() (record, error) {
+	var buf []byte
+	for len(bytes.TrimSpace(buf)) == 0 {
+		var err error
+		buf, err = j.r.ReadBytes('\n')
+		if len(buf) == 0 || (err != nil && err != io.EOF) {
+			return record{}, err
+		}
+		j.line++
+	}
+
+	var jr jsonRecord
+	if err := json.Unmarshal(buf, &jr); err != nil {
+		return record{}, fmt.Errorf("Line %d: %v", j.line, err)
+	} else if jr.Key == nil {
+		return record{}, fmt.Errorf("Line %d: missing key", j.line)
+	}
+	r, err := j.f.decodeRecord(*jr.Key, jr.Value, jr.Extra)
+	if err != nil {
+		return r, fmt.Errorf("Line %d: %v", j.line, err)
+	}
+	return r, nil
+}
+
+func (f *commandFlags) decodeRecord(key, value, extra string) (r record, err error) {
+	if r.key, err = f.keyEncoding.decode(key); err != nil {
+		return
+	}
+	if r.value, err = f.valueEncoding.decode(value); err != nil {
+		return
+	}
+	r.extra, err = f.extraEncoding.decode(extra)
+	return
+}
+
+func newInputReader(format string, in io.Reader, f *commandFlags) (inputReader, error) {
+	switch format {
+	case "csv":
+		return newDelimitedReader(in, ',', f), nil
+	case "tsv":
+		return newDelimitedReader(in, '\t', f), nil
+	case "jsonl":
+		return &jsonlReader{r: bufio.NewReader(in), f: f}, nil
+	}
+	return nil, fmt.Errorf("Unknown format %q, expected one of csv, tsv, jsonl", format)
+}
+
+var duplicatePolicies = map[string]sstable.DuplicatePolicy{
+	"error": sstable.DuplicateError,
+	"first": sstable.DuplicateKeepFirst,
+	"last":  sstable.DuplicateKeepLast,
+}
+
+func runBuild(args []string) error {
+	f := newCommandFlags("build")
+	format := f.String("format", "", "Input format: csv, tsv or jsonl. Defaults to the input file extension")
+	memLimit := f.Int("sort_memory", 256*1024*1024, "Memory used for sorting input before spilling to disk (bytes)")
+	tmpDir := f.String("tmp_dir", "", "Directory for temporary sort files")
+	maxKeyLength := f.Int("max_key_length", 0, "Maximum key length. If 0, the default is used")
+	checksums := f.Bool("checksums", true, "Store value checksums")
+	alignment := f.Int("value_alignment", 0, "Align values to a multiple of this many bytes. If 0, values are not aligned")
+	alignThreshold := f.Uint64("alignment_threshold", 0, "Only align values at least this long")
+	duplicates := f.String("duplicates", "error", "Handling of duplicate keys: error, first or last")
+	if err := f.parse(args, 2); err != nil {
+		return err
+	}
+	if a := *alignment; a < 0 || a&(a-1) != 0 {
+		return fmt.Errorf("Value alignment %d is not a power of 2", a)
+	} else if *memLimit <= 0 {
+		return fmt.Errorf("Invalid sort memory %d", *memLimit)
+	}
+	policy, ok := duplicatePolicies[*duplicates]
+	if !ok {
+		return fmt.Errorf("Unknown duplicate handling %q, expected one of error, first, last", *duplicates)
+	}
+
+	outPath, inPath := f.Arg(0), f.Arg(1)
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(inPath), ".")
+	}
+	var in io.Reader = os.Stdin
+	if inPath != "-" {
+		inFile, err := os.Open(inPath)
+		if err != nil {
+			return err
+		}
+		defer inFile.Close()
+		in = inFile
+	}
+	input, err := newInputReader(*format, in, f)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	err = buildTable(out, input, sstable.SortingBuilderOptions{
+		BuilderOptions: sstable.BuilderOptions{
+			MaxKeyLength:       *maxKeyLength,
+			Checksums:          *checksums,
+			ValueAlignment:     *alignment,
+			AlignmentThreshold: *alignThreshold,
+		},
+		MemoryLimit: *memLimit,
+		TempDir:     *tmpDir,
+		Duplicates:  policy,
+	})
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(outPath)
+	}
+	return err
+}
+
+func buildTable(out io.Writer, input inputReader, opts sstable.SortingBuilderOptions) error {
+	b := sstable.NewSortingBuilder(out, opts)
+	for {
+		r, err := input.read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			b.Abort()
+			return err
+		}
+		if err = b.Add(r.key, r.value, r.extra); err != nil {
+			b.Abort()
+			return err
+		}
+	}
+	return b.Build()
+}
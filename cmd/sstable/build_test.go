@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, format, input string, f *commandFlags) ([]record, error) {
+	r, err := newInputReader(format, strings.NewReader(input), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []record
+	for {
+		rec, err := r.read()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestInputReader(t *testing.T) {
+	defaultFlags := &commandFlags{keyEncoding: encodingUTF8, valueEncoding: encodingUTF8, extraEncoding: encodingHex}
+	hexFlags := &commandFlags{keyEncoding: encodingHex, valueEncoding: encodingBase64, extraEncoding: encodingRaw}
+
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		f        *commandFlags
+		expected []record
+	}{
+		{"csv", "csv", "a,1\nb,2,0102\n", defaultFlags, []record{
+			{key: []byte("a"), value: []byte("1"), extra: []byte{}},
+			{key: []byte("b"), value: []byte("2"), extra: []byte{1, 2}},
+		}},
+		{"csv quoted", "csv", "\"a,b\",\"x\"\"y\"\n", defaultFlags, []record{
+			{key: []byte("a,b"), value: []byte("x\"y"), extra: []byte{}},
+		}},
+		{"csv empty value", "csv", "a,\n", defaultFlags, []record{
+			{key: []byte("a"), value: []byte{}, extra: []byte{}},
+		}},
+		{"tsv", "tsv", "a\t1\nb\"c\t2\tff\n", defaultFlags, []record{
+			{key: []byte("a"), value: []byte("1"), extra: []byte{}},
+			{key: []byte("b\"c"), value: []byte("2"), extra: []byte{0xff}},
+		}},
+		{"jsonl", "jsonl", "{\"key\":\"a\",\"value\":\"1\"}\n{\"key\":\"\",\"value\":\"2\",\"extra\":\"03\"}\n", defaultFlags, []record{
+			{key: []byte("a"), value: []byte("1"), extra: []byte{}},
+			{key: []byte{}, value: []byte("2"), extra: []byte{3}},
+		}},
+		{"encodings", "csv", "6162,Zm9v,x\n", hexFlags, []record{
+			{key: []byte("ab"), value: []byte("foo"), extra: []byte("x")},
+		}},
+		{"empty", "jsonl", "", defaultFlags, nil},
+		{"csv multi-line", "csv", "a,\"x\ny\"\nb,2\n", defaultFlags, []record{
+			{key: []byte("a"), value: []byte("x\ny"), extra: []byte{}},
+			{key: []byte("b"), value: []byte("2"), extra: []byte{}},
+		}},
+		{"jsonl blank lines", "jsonl", "\n{\"key\":\"a\"}\n  \n{\"key\":\"b\"}", defaultFlags, []record{
+			{key: []byte("a"), value: []byte{}, extra: []byte{}},
+			{key: []byte("b"), value: []byte{}, extra: []byte{}},
+		}},
+	}
+	for _, test := range tests {
+		records, err := readAll(t, test.format, test.input, test.f)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if len(records) != len(test.expected) {
+			t.Errorf("%s: got %d records, expected %d", test.name, len(records), len(test.expected))
+			continue
+		}
+		for i, r := range records {
+			e := test.expected[i]
+			if !bytes.Equal(r.key, e.key) || !bytes.Equal(r.value, e.value) || !bytes.Equal(r.extra, e.extra) {
+				t.Errorf("%s: record %d = %q, expected %q", test.name, i, r, e)
+			}
+		}
+	}
+}
+
+func TestInputReader_Error(t *testing.T) {
+	f := &commandFlags{keyEncoding: encodingUTF8, valueEncoding: encodingUTF8, extraEncoding: encodingHex}
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		// Errors report the line the record starts on.
+		line int
+	}{
+		{"csv one field", "csv", "a\n", 1},
+		{"csv four fields", "csv", "a,1,00,x\n", 1},
+		{"csv bad extra", "csv", "a,1\nb,2,zz\n", 2},
+		{"csv multi-line", "csv", "a,\"x\ny\"\nb\n", 3},
+		{"csv multi-line record", "csv", "a,1\nb,\"x\ny\",zz\n", 2},
+		{"tsv one field", "tsv", "a\t1\nb\n", 2},
+		{"jsonl missing key", "jsonl", "{\"value\":\"1\"}\n", 1},
+		{"jsonl bad extra", "jsonl", "\n{\"key\":\"a\",\"extra\":\"zz\"}\n", 2},
+		{"jsonl invalid", "jsonl", "{\"key\":\"a\"}\n{\"key\":\n", 2},
+		{"jsonl two lines", "jsonl", "{\"key\":\n\"a\"}\n", 1},
+	}
+	for _, test := range tests {
+		_, err := readAll(t, test.format, test.input, f)
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+		} else if prefix := fmt.Sprintf("Line %d: ", test.line); !strings.HasPrefix(err.Error(), prefix) {
+			t.Errorf("%s: error %q, expected prefix %q", test.name, err, prefix)
+		}
+	}
+
+	if _, err := newInputReader("xml", strings.NewReader(""), f); err == nil {
+		t.Error("Expected unknown format error")
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	sstable "github.com/akmistry/simple-sstable"
+)
+
+type entryJSON struct {
+	Key    string  `json:"key"`
+	Length uint64  `json:"length"`
+	Extra  string  `json:"extra,omitempty"`
+	Value  *string `json:"value,omitempty"`
+}
+
+func readValue(r *sstable.ValueReader) ([]byte, error) {
+	value := make([]byte, int(r.Size()))
+	n, err := r.ReadAt(value, 0)
+	if err == io.EOF && n == len(value) {
+		err = nil
+	}
+	return value, err
+}
+
+func runStats(args []string) error {
+	f := newCommandFlags("stats")
+	histograms := f.Bool("histograms", true, "Print size distributions")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	defer f.out.Flush()
+
+	table, err := openTable(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	stats := table.Stats()
+	var hist *sstable.TableHistogram
+	if *histograms {
+		h := table.Histogram()
+		hist = &h
+	}
+	if f.json {
+		return f.writeJSON(struct {
+			sstable.TableStats
+			LoadTimeMs float64
+			Histogram  *sstable.TableHistogram `json:",omitempty"`
+		}{stats, float64(table.loadTime.Microseconds()) / 1000, hist})
+	}
+	fmt.Fprintln(f.out, "Table load time:", table.loadTime)
+	fmt.Fprintln(f.out, "Header size:", stats.HeaderSize)
+	fmt.Fprintln(f.out, "Index size:", stats.IndexSize)
+	fmt.Fprintln(f.out, "Index memory:", stats.IndexMemory)
+	fmt.Fprintln(f.out, "Num keys:", stats.NumKeys)
+	fmt.Fprintln(f.out, "Keys size:", stats.KeysSize)
+	fmt.Fprintln(f.out, "Values size:", stats.ValuesSize)
+	fmt.Fprintln(f.out, "Stored values size:", stats.StoredValuesSize)
+	fmt.Fprintln(f.out, "Padding size:", stats.PaddingSize)
+	if hist != nil {
+		printDistribution(f.out, "Key size", hist.KeySize)
+		printDistribution(f.out, "Value size", hist.ValueSize)
+		printDistribution(f.out, "Extra size", hist.ExtraSize)
+		printDistribution(f.out, "Shared key prefix", hist.SharedPrefix)
+		if stats.KeysSize > 0 {
+			fmt.Fprintf(f.out, "Key bytes shared with previous key: %d (%.1f%%)\n",
+				hist.SharedPrefix.Total, 100*float64(hist.SharedPrefix.Total)/float64(stats.KeysSize))
+		}
+	}
+	return nil
+}
+
+// Width of the longest histogram bar.
+const histogramWidth = 40
+
+func printDistribution(w io.Writer, name string, d sstable.SizeDistribution) {
+	fmt.Fprintf(w, "%s: count=%d total=%d min=%d max=%d mean=%.1f p50=%d p90=%d p99=%d\n",
+		name, d.Count, d.Total, d.Min, d.Max, d.Mean, d.P50, d.P90, d.P99)
+	maxCount := 0
+	for _, c := range d.Buckets {
+		if c > maxCount {
+			maxCount = c
+		}
+	}
+	for i, c := range d.Buckets {
+		if c == 0 {
+			continue
+		}
+		lo, hi := sstable.BucketRange(i)
+		bar := strings.Repeat("#", (c*histogramWidth+maxCount-1)/maxCount)
+		fmt.Fprintf(w, "  [%d, %d]\t%d\t%s\n", lo, hi, c, bar)
+	}
+}
+
+func runKeys(args []string) error {
+	f := newCommandFlags("keys")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	defer f.out.Flush()
+
+	table, err := openTable(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	for iter := table.Seek(nil); iter.Valid(); iter.Next() {
+		key := f.keyEncoding.encode(iter.Key())
+		if f.json {
+			err = f.writeJSON(key)
+		} else {
+			_, err = fmt.Fprintln(f.out, key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runGet(args []string) error {
+	f := newCommandFlags("get")
+	if err := f.parse(args, 2); err != nil {
+		return err
+	}
+	defer f.out.Flush()
+
+	key, err := f.keyEncoding.decode(f.Arg(1))
+	if err != nil {
+		return err
+	}
+	table, err := openTable(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	value, extra, err := table.Get(key)
+	if err != nil {
+		return err
+	}
+	if f.json {
+		v := f.valueEncoding.encode(value)
+		return f.writeJSON(entryJSON{
+			Key:    f.keyEncoding.encode(key),
+			Length: uint64(len(value)),
+			Extra:  f.extraEncoding.encode(extra),
+			Value:  &v,
+		})
+	} else if f.valueEncoding == encodingRaw {
+		_, err = f.out.Write(value)
+		return err
+	}
+	_, err = fmt.Fprintln(f.out, f.valueEncoding.encode(value))
+	return err
+}
+
+// Prints entries from iter, until end (exclusive) or the key no longer has the
+// given prefix.
+func printEntries(f *commandFlags, iter *sstable.Iter, end, prefix []byte, withValues bool) error {
+	for ; iter.Valid(); iter.Next() {
+		key := iter.Key()
+		if end != nil && bytes.Compare(key, end) >= 0 {
+			break
+		} else if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+
+		entry := entryJSON{
+			Key:    f.keyEncoding.encode(key),
+			Length: uint64(iter.ValueSize()),
+			Extra:  f.extraEncoding.encode(iter.Extra()),
+		}
+		if withValues {
+			value, err := readValue(iter.Reader())
+			if err != nil {
+				return fmt.Errorf("Error reading value for key %q: %v", entry.Key, err)
+			}
+			v := f.valueEncoding.encode(value)
+			entry.Value = &v
+		}
+
+		var err error
+		if f.json {
+			err = f.writeJSON(entry)
+		} else if entry.Value != nil {
+			_, err = fmt.Fprintf(f.out, "%s\t%d\t%s\t%s\n", entry.Key, entry.Length, entry.Extra, *entry.Value)
+		} else {
+			_, err = fmt.Fprintf(f.out, "%s\t%d\t%s\n", entry.Key, entry.Length, entry.Extra)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runScan(args []string) error {
+	f := newCommandFlags("scan")
+	startFlag := f.String("start", "", "First key to scan (inclusive)")
+	endFlag := f.String("end", "", "Last key to scan (exclusive)")
+	prefixFlag := f.String("prefix", "", "Only scan keys with this prefix")
+	withValues := f.Bool("values", false, "Print values")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	defer f.out.Flush()
+
+	start, err := f.keyEncoding.decode(*startFlag)
+	if err != nil {
+		return err
+	}
+	var end []byte
+	if *endFlag != "" {
+		if end, err = f.keyEncoding.decode(*endFlag); err != nil {
+			return err
+		}
+	}
+	prefix, err := f.keyEncoding.decode(*prefixFlag)
+	if err != nil {
+		return err
+	}
+	if bytes.Compare(start, prefix) < 0 {
+		start = prefix
+	}
+
+	table, err := openTable(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	iter := table.Seek(start)
+	if *withValues {
+		iter = table.SeekReadAhead(start, sstable.ReadAheadOptions{Background: true})
+		defer iter.Close()
+	}
+	return printEntries(f, iter, end, prefix, *withValues)
+}
+
+func runDump(args []string) error {
+	f := newCommandFlags("dump")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	defer f.out.Flush()
+
+	table, err := openTable(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	iter := table.SeekReadAhead(nil, sstable.ReadAheadOptions{Background: true})
+	defer iter.Close()
+	return printEntries(f, iter, nil, nil, true)
+}
+
+func runVerify(args []string) error {
+	f := newCommandFlags("verify")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	defer f.out.Flush()
+
+	table, err := openTable(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	report, err := table.Verify(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if f.json {
+		problems := make([]string, 0, len(report.Problems))
+		for _, p := range report.Problems {
+			problems = append(problems, p.String())
+		}
+		err = f.writeJSON(struct {
+			OK                bool
+			NumKeys           int
+			ChecksumsVerified int
+			NumProblems       int
+			Problems          []string
+		}{report.OK(), report.NumKeys, report.ChecksumsVerified, report.NumProblems, problems})
+	} else {
+		for _, p := range report.Problems {
+			fmt.Fprintln(f.out, p)
+		}
+		_, err = fmt.Fprintf(f.out, "Checked %d keys, %d checksums verified, %d problems\n",
+			report.NumKeys, report.ChecksumsVerified, report.NumProblems)
+	}
+	if err != nil {
+		return err
+	} else if !report.OK() {
+		return errFailed
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	sstable "github.com/akmistry/simple-sstable"
+)
+
+type diffEntryJSON struct {
+	Type    string   `json:"type"`
+	Key     string   `json:"key"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Written after the entries in JSON output, with type "summary".
+type diffSummary struct {
+	Type    string `json:"type"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Changed int    `json:"changed"`
+}
+
+func runDiff(args []string) error {
+	f := newCommandFlags("diff")
+	compareValues := f.Bool("values", false, "Compare values (using checksums if both tables have them)")
+	ignoreChecksums := f.Bool("ignore_checksums", false, "Compare value bytes even if checksums are available")
+	summaryOnly := f.Bool("summary", false, "Only print counts of differences")
+	if err := f.parse(args, 2); err != nil {
+		return err
+	}
+	defer f.out.Flush()
+
+	a, err := openTable(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	b, err := openTable(f.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	summary := diffSummary{Type: "summary"}
+	iter := sstable.DiffWithOptions(a.Table, b.Table, sstable.DiffOptions{
+		CompareValues:   *compareValues,
+		IgnoreChecksums: *ignoreChecksums,
+	})
+	for iter.Next() {
+		e := iter.Entry()
+		switch e.Type {
+		case sstable.DiffAdded:
+			summary.Added++
+		case sstable.DiffRemoved:
+			summary.Removed++
+		case sstable.DiffChanged:
+			summary.Changed++
+		}
+		if *summaryOnly {
+			continue
+		}
+
+		entry := diffEntryJSON{Type: e.Type.String(), Key: f.keyEncoding.encode(e.Key)}
+		if e.LengthChanged {
+			entry.Changed = append(entry.Changed, "length")
+		}
+		if e.ExtraChanged {
+			entry.Changed = append(entry.Changed, "extra")
+		}
+		if e.ValueChanged {
+			entry.Changed = append(entry.Changed, "value")
+		}
+
+		if f.json {
+			err = f.writeJSON(entry)
+		} else if entry.Changed != nil {
+			_, err = fmt.Fprintf(f.out, "%s\t%s\t%s\n", entry.Type, entry.Key, strings.Join(entry.Changed, ","))
+		} else {
+			_, err = fmt.Fprintf(f.out, "%s\t%s\n", entry.Type, entry.Key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if iter.Err() != nil {
+		return iter.Err()
+	}
+
+	if f.json {
+		return f.writeJSON(summary)
+	}
+	_, err = fmt.Fprintf(f.out, "Added: %d, removed: %d, changed: %d\n", summary.Added, summary.Removed, summary.Changed)
+	return err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Encoding used to print keys and values, and to parse keys given as
+// arguments.
+type encoding string
+
+const (
+	encodingRaw    encoding = "raw"
+	encodingHex    encoding = "hex"
+	encodingBase64 encoding = "base64"
+	encodingUTF8   encoding = "utf8"
+)
+
+func parseEncoding(s string) (encoding, error) {
+	switch e := encoding(s); e {
+	case encodingRaw, encodingHex, encodingBase64, encodingUTF8:
+		return e, nil
+	}
+	return "", fmt.Errorf("Unknown encoding %q, expected one of raw, hex, base64, utf8", s)
+}
+
+func (e encoding) encode(b []byte) string {
+	switch e {
+	case encodingHex:
+		return hex.EncodeToString(b)
+	case encodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case encodingUTF8:
+		return strings.ToValidUTF8(string(b), "�")
+	}
+	return string(b)
+}
+
+func (e encoding) decode(s string) ([]byte, error) {
+	switch e {
+	case encodingHex:
+		return hex.DecodeString(s)
+	case encodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseEncoding(t *testing.T) {
+	for _, s := range []string{"raw", "hex", "base64", "utf8"} {
+		if e, err := parseEncoding(s); err != nil || string(e) != s {
+			t.Error("Unexpected encoding", s, e, err)
+		}
+	}
+	for _, s := range []string{"", "HEX", "base32"} {
+		if _, err := parseEncoding(s); err == nil {
+			t.Error("Expected error for", s)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		e       encoding
+		data    []byte
+		encoded string
+	}{
+		{encodingRaw, []byte("foo"), "foo"},
+		{encodingRaw, []byte{0xff, 0}, "\xff\x00"},
+		{encodingHex, []byte{0xde, 0xad, 0xbe, 0xef}, "deadbeef"},
+		{encodingHex, nil, ""},
+		{encodingBase64, []byte("foobar"), "Zm9vYmFy"},
+		{encodingBase64, []byte{0xff, 0xfe}, "//4="},
+		{encodingUTF8, []byte("héllo"), "héllo"},
+	}
+	for _, test := range tests {
+		if s := test.e.encode(test.data); s != test.encoded {
+			t.Errorf("%s encode(%q) = %q, expected %q", test.e, test.data, s, test.encoded)
+		}
+		b, err := test.e.decode(test.encoded)
+		if err != nil {
+			t.Errorf("%s decode(%q) error: %v", test.e, test.encoded, err)
+		} else if !bytes.Equal(b, test.data) {
+			t.Errorf("%s decode(%q) = %q, expected %q", test.e, test.encoded, b, test.data)
+		}
+	}
+}
+
+func TestEncoding_InvalidUTF8(t *testing.T) {
+	if s := encodingUTF8.encode([]byte("a\xffb")); s != "a�b" {
+		t.Error("Unexpected encoding", s)
+	}
+}
+
+func TestEncoding_DecodeError(t *testing.T) {
+	tests := []struct {
+		e encoding
+		s string
+	}{
+		{encodingHex, "abc"},
+		{encodingHex, "zz"},
+		{encodingBase64, "Zm9vY"},
+		{encodingBase64, "!!!!"},
+	}
+	for _, test := range tests {
+		if _, err := test.e.decode(test.s); err == nil {
+			t.Errorf("Expected %s decode(%q) error", test.e, test.s)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	sstable "github.com/akmistry/simple-sstable"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	// Initialised here since commands refer back to this map for usage.
+	commands = map[string]command{
+		"stats":  {"stats [flags] <file>", runStats},
+		"keys":   {"keys [flags] <file>", runKeys},
+		"get":    {"get [flags] <file> <key>", runGet},
+		"scan":   {"scan [flags] <file>", runScan},
+		"dump":   {"dump [flags] <file>", runDump},
+		"verify": {"verify [flags] <file>", runVerify},
+		"build":  {"build [flags] <output file> <input file, or - for stdin>", runBuild},
+		"diff":   {"diff [flags] <file a> <file b>", runDiff},
+		"split":  {"split [flags] <file> <output prefix>", runSplit},
+	}
+}
+
+// Returned by commands which have already reported the failure.
+var errFailed = errors.New("Failed")
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: sstable <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  sstable", commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "Run 'sstable <command> -h' for command flags.")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(os.Args[2:])
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	} else if err == errFailed {
+		os.Exit(1)
+	} else if err != nil {
+		log.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+// Flags shared by all commands.
+type commandFlags struct {
+	*flag.FlagSet
+
+	keyEncodingFlag   string
+	valueEncodingFlag string
+	extraEncodingFlag string
+	json              bool
+
+	keyEncoding   encoding
+	valueEncoding encoding
+	extraEncoding encoding
+
+	out *bufio.Writer
+}
+
+func newCommandFlags(name string) *commandFlags {
+	f := &commandFlags{FlagSet: flag.NewFlagSet(name, flag.ContinueOnError)}
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: sstable", commands[name].usage)
+		f.PrintDefaults()
+	}
+	f.StringVar(&f.keyEncodingFlag, "key_encoding", "utf8", "Key encoding: raw, hex, base64 or utf8")
+	f.StringVar(&f.valueEncodingFlag, "value_encoding", "utf8", "Value encoding: raw, hex, base64 or utf8")
+	f.StringVar(&f.extraEncodingFlag, "extra_encoding", "hex", "Extra encoding: raw, hex, base64 or utf8")
+	f.BoolVar(&f.json, "json", false, "Output JSON")
+	return f
+}
+
+// Parses args, and checks the number of positional arguments.
+func (f *commandFlags) parse(args []string, numArgs int) error {
+	err := f.Parse(args)
+	if err != nil {
+		return err
+	}
+	if f.NArg() != numArgs {
+		f.Usage()
+		return flag.ErrHelp
+	}
+
+	if f.keyEncoding, err = parseEncoding(f.keyEncodingFlag); err != nil {
+		return err
+	}
+	if f.valueEncoding, err = parseEncoding(f.valueEncodingFlag); err != nil {
+		return err
+	}
+	if f.extraEncoding, err = parseEncoding(f.extraEncodingFlag); err != nil {
+		return err
+	}
+	f.out = bufio.NewWriter(os.Stdout)
+	return nil
+}
+
+func (f *commandFlags) writeJSON(v interface{}) error {
+	return json.NewEncoder(f.out).Encode(v)
+}
+
+type loadedTable struct {
+	*sstable.Table
+	f        *os.File
+	loadTime time.Duration
+}
+
+func openTable(path string) (*loadedTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	table, err := sstable.Load(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &loadedTable{Table: table, f: f, loadTime: time.Since(startTime)}, nil
+}
+
+func (t *loadedTable) Close() error {
+	t.Table.Close()
+	return t.f.Close()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	sstable "github.com/akmistry/simple-sstable"
+)
+
+func runSplit(args []string) error {
+	f := newCommandFlags("split")
+	boundariesFlag := f.String("boundaries", "", "Comma separated keys at which new tables start")
+	targetSize := f.Uint64("target_size", 0, "Approximate size of each new table (bytes), if -boundaries is not given")
+	checksums := f.Bool("checksums", true, "Store value checksums")
+	if err := f.parse(args, 2); err != nil {
+		return err
+	}
+	defer f.out.Flush()
+
+	opts := sstable.SplitOptions{
+		TargetSize:     *targetSize,
+		BuilderOptions: sstable.BuilderOptions{Checksums: *checksums},
+	}
+	if *boundariesFlag != "" {
+		for _, s := range strings.Split(*boundariesFlag, ",") {
+			b, err := f.keyEncoding.decode(s)
+			if err != nil {
+				return err
+			}
+			opts.Boundaries = append(opts.Boundaries, b)
+		}
+	} else if *targetSize == 0 {
+		return fmt.Errorf("One of -boundaries or -target_size must be given")
+	}
+
+	table, err := openTable(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	prefix := f.Arg(1)
+	var paths []string
+	factory := func(i int) (io.Writer, error) {
+		path := fmt.Sprintf("%s-%05d.sst", prefix, i)
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		return file, nil
+	}
+	tables, err := sstable.Split(table.Table, opts, factory)
+	if err != nil {
+		// Don't leave partial output behind.
+		for _, path := range paths {
+			os.Remove(path)
+		}
+		return err
+	}
+
+	for i, t := range tables {
+		first, last := f.keyEncoding.encode(t.FirstKey), f.keyEncoding.encode(t.LastKey)
+		if f.json {
+			err = f.writeJSON(struct {
+				Path     string `json:"path"`
+				FirstKey string `json:"first_key"`
+				LastKey  string `json:"last_key"`
+				NumKeys  int    `json:"num_keys"`
+			}{paths[i], first, last, t.NumKeys})
+		} else {
+			_, err = fmt.Fprintf(f.out, "%s\t%s\t%s\t%d\n", paths[i], first, last, t.NumKeys)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package sstable
+
+import (
+	"context"
+	"io"
+)
+
+// ReaderAtContext is implemented by readers that can cancel reads, such as
+// network-backed files. If the io.ReaderAt passed to Load implements this
+// interface, contexts passed to the *Context methods are propagated to it.
+type ReaderAtContext interface {
+	ReadAtContext(ctx context.Context, p []byte, off int64) (int, error)
+}
+
+func readAtContext(ctx context.Context, r io.ReaderAt, p []byte, off int64) (int, error) {
+	if rc, ok := r.(ReaderAtContext); ok {
+		return rc.ReadAtContext(ctx, p, off)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadAt(p, off)
+}
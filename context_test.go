@@ -0,0 +1,49 @@
+package sstable
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+type ctxReader struct {
+	*bytes.Reader
+	calls int
+}
+
+func (r *ctxReader) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+	r.calls++
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadAt(p, off)
+}
+
+func TestContext(t *testing.T) {
+	buf := buildTable(t, testValues)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := LoadContext(cancelled, bytes.NewReader(buf), LoadOptions{})
+	if err != context.Canceled {
+		t.Error("Expected Canceled, got", err)
+	}
+
+	r := &ctxReader{Reader: bytes.NewReader(buf)}
+	table, err := LoadContext(context.Background(), r, LoadOptions{})
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	} else if r.calls == 0 {
+		t.Error("Expected ReadAtContext to be used")
+	}
+	checkTable(t, table, testValues)
+
+	_, _, err = table.GetContext(cancelled, []byte("foo"))
+	if err != context.Canceled {
+		t.Error("Expected Canceled, got", err)
+	}
+	v, _, err := table.GetContext(context.Background(), []byte("foo"))
+	if err != nil || string(v) != "bar1" {
+		t.Error("Unexpected value", v, err)
+	}
+}
@@ -0,0 +1,47 @@
+package sstable
+
+import (
+	"crypto/sha256"
+	"log"
+)
+
+type valueHash struct {
+	sum    [sha256.Size]byte
+	length uint64
+}
+
+// Hashes every value and encodes the index, with entries whose values are
+// identical to an earlier value sharing its offset.
+func (b *Builder) dedupValues() error {
+	seen := make(map[valueHash]int)
+	var prev []byte
+	for i := range b.keys {
+		p := &b.keys[i]
+		if p.length > 0 {
+			h := sha256.New()
+			n, err := b.vf(p.key, h)
+			if err != nil {
+				return err
+			} else if uint64(n) != p.length {
+				log.Panicf("Unexpected value write length %d, expected %d", n, p.length)
+			}
+
+			vh := valueHash{length: p.length}
+			h.Sum(vh.sum[:0])
+			if j, ok := seen[vh]; ok {
+				p.sameAs = j + 1
+				p.offset = b.keys[j].offset
+			} else {
+				seen[vh] = i
+				p.offset = b.placeValue(p.length)
+			}
+		} else {
+			p.offset = b.placeValue(0)
+		}
+
+		b.encodeEntry(i, prev, p.key, p.offset, p.length, p.extra)
+		p.extra = nil
+		prev = p.key
+	}
+	return nil
+}
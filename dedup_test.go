@@ -0,0 +1,100 @@
+package sstable
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+func TestDedup(t *testing.T) {
+	entries := generateEntries(100)
+	for _, opts := range []BuilderOptions{
+		{Dedup: true},
+		{Dedup: true, Checksums: true},
+		{Dedup: true, KeyProvider: &testKeyProvider{mask: 1}},
+	} {
+		buf := buildTableWithOptions(t, entries, opts)
+		table, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{KeyProvider: opts.KeyProvider})
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		}
+		checkTable(t, table, entries)
+
+		stats := table.Stats()
+		// Values i%10 * 37 bytes long, so each 10 entries have 45*37 bytes of
+		// values, and there are 9 distinct non-empty values.
+		if stats.ValuesSize != 10*45*37 {
+			t.Error("Unexpected values size", stats.ValuesSize)
+		}
+		if opts.KeyProvider == nil && stats.StoredValuesSize != 45*37 {
+			t.Error("Unexpected stored values size", stats.StoredValuesSize)
+		}
+
+		report, err := table.Verify(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		} else if !report.OK() {
+			t.Error("Unexpected problems", report.Problems)
+		} else if report.SharedValues != 90-9 {
+			t.Error("Unexpected shared values", report.SharedValues)
+		}
+		if opts.Checksums && report.ChecksumsVerified != 100 {
+			t.Error("Unexpected checksums verified", report.ChecksumsVerified)
+		}
+
+		if opts.KeyProvider == nil {
+			// 7 byte keys.
+			if s := table.ApproximateSize([]byte("key0010"), []byte("key0020")); s != 10*7+45*37 {
+				t.Error("Unexpected size", s)
+			}
+			if s := table.ApproximateSize(nil, nil); s != 100*7+10*45*37 {
+				t.Error("Unexpected size", s)
+			}
+		}
+	}
+}
+
+func TestDedup_Disabled(t *testing.T) {
+	table, err := buildReader(t, buildTable(t, generateEntries(100)))
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	if stats := table.Stats(); stats.StoredValuesSize != stats.ValuesSize {
+		t.Error("Unexpected stored values size", stats.StoredValuesSize, stats.ValuesSize)
+	}
+}
+
+func TestDedup_Split(t *testing.T) {
+	entries := generateEntries(100)
+	table, err := buildReader(t, buildTableWithOptions(t, entries, BuilderOptions{Dedup: true}))
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+
+	var bufs []*bytes.Buffer
+	tables, err := Split(table, SplitOptions{
+		Boundaries:     [][]byte{[]byte("key0050")},
+		BuilderOptions: BuilderOptions{Dedup: true},
+	}, func(i int) (io.Writer, error) {
+		bufs = append(bufs, new(bytes.Buffer))
+		return bufs[i], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(tables) != 2 {
+		t.Fatal("Unexpected tables", tables)
+	}
+	for _, buf := range bufs {
+		st, err := buildReader(t, buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for iter := st.Seek(nil); iter.Valid(); iter.Next() {
+			v, _, err := st.Get(iter.Key())
+			if err != nil || string(v) != entries[string(iter.Key())].val {
+				t.Error("Unexpected value", iter.Key(), v, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package sstable
+
+import (
+	"bytes"
+	"io"
+)
+
+type DiffType int
+
+const (
+	// Key is only in the second table.
+	DiffAdded DiffType = iota
+	// Key is only in the first table.
+	DiffRemoved
+	// Key is in both tables, but the entry differs.
+	DiffChanged
+)
+
+func (t DiffType) String() string {
+	switch t {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+type DiffEntry struct {
+	Type DiffType
+	Key  []byte
+
+	// For DiffChanged entries, which parts of the entry changed.
+	LengthChanged bool
+	ExtraChanged  bool
+	ValueChanged  bool
+}
+
+type DiffOptions struct {
+	// Compare values, in addition to lengths and extra data. If both tables
+	// have checksums, the checksums are compared instead of the value bytes.
+	CompareValues bool
+
+	// Always compare value bytes, even if both tables have checksums.
+	IgnoreChecksums bool
+}
+
+// Iterates over the differences between two tables, in key order.
+type DiffIter struct {
+	a, b *Table
+	opts DiffOptions
+
+	ai, bi int
+	entry  DiffEntry
+	err    error
+
+	abuf, bbuf []byte
+}
+
+func Diff(a, b *Table) *DiffIter {
+	return DiffWithOptions(a, b, DiffOptions{})
+}
+
+func DiffWithOptions(a, b *Table, opts DiffOptions) *DiffIter {
+	return &DiffIter{a: a, b: b, opts: opts}
+}
+
+// Advances to the next difference. Returns false when there are no more
+// differences, or an error occurred.
+func (d *DiffIter) Next() bool {
+	for d.err == nil {
+		aDone := d.ai >= d.a.index.len()
+		bDone := d.bi >= d.b.index.len()
+		if aDone && bDone {
+			return false
+		}
+
+		cmp := 0
+		if aDone {
+			cmp = 1
+		} else if bDone {
+			cmp = -1
+		} else {
+			cmp = bytes.Compare(d.a.index.key(d.ai), d.b.index.key(d.bi))
+		}
+
+		switch {
+		case cmp < 0:
+			d.entry = DiffEntry{Type: DiffRemoved, Key: d.a.index.key(d.ai)}
+			d.ai++
+			return true
+		case cmp > 0:
+			d.entry = DiffEntry{Type: DiffAdded, Key: d.b.index.key(d.bi)}
+			d.bi++
+			return true
+		}
+
+		d.entry = DiffEntry{Type: DiffChanged, Key: d.a.index.key(d.ai)}
+		d.compare(d.ai, d.bi)
+		if d.err != nil {
+			return false
+		}
+		d.ai++
+		d.bi++
+		if d.entry.LengthChanged || d.entry.ExtraChanged || d.entry.ValueChanged {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DiffIter) compare(ai, bi int) {
+	d.entry.LengthChanged = d.a.index.length(ai) != d.b.index.length(bi)
+	d.entry.ExtraChanged = !bytes.Equal(d.a.index.extra(ai), d.b.index.extra(bi))
+	if !d.opts.CompareValues {
+		return
+	} else if d.entry.LengthChanged {
+		d.entry.ValueChanged = true
+		return
+	}
+
+	if !d.opts.IgnoreChecksums && d.a.checksums != nil && d.b.checksums != nil {
+		d.entry.ValueChanged = d.a.checksums[ai] != d.b.checksums[bi]
+		return
+	}
+
+	equal, err := d.equalValues(d.a.valueReader(ai), d.b.valueReader(bi))
+	if err != nil {
+		d.err = err
+	}
+	d.entry.ValueChanged = !equal
+}
+
+// Size of reads when comparing values.
+const diffReadSize = 64 * 1024
+
+func (d *DiffIter) equalValues(ar, br *ValueReader) (bool, error) {
+	if d.abuf == nil {
+		d.abuf = make([]byte, diffReadSize)
+		d.bbuf = make([]byte, diffReadSize)
+	}
+	for off := int64(0); off < ar.Size(); off += diffReadSize {
+		an, err := ar.ReadAt(d.abuf, off)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		bn, err := br.ReadAt(d.bbuf, off)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if !bytes.Equal(d.abuf[:an], d.bbuf[:bn]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Returns the current difference.
+func (d *DiffIter) Entry() DiffEntry {
+	return d.entry
+}
+
+// Returns the error, if any, that stopped iteration.
+func (d *DiffIter) Err() error {
+	return d.err
+}
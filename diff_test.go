@@ -0,0 +1,84 @@
+package sstable
+
+import (
+	"testing"
+)
+
+var diffValuesA = map[string]testValuePair{
+	"removed":        {"gone", nil},
+	"same":           {"value", []byte{1}},
+	"length":         {"short", nil},
+	"extra":          {"value", []byte{1}},
+	"value":          {"value1", nil},
+	"zzz-unchanged":  {"", nil},
+	"zzz-removed-to": {"x", nil},
+}
+
+var diffValuesB = map[string]testValuePair{
+	"added":         {"new", nil},
+	"same":          {"value", []byte{1}},
+	"length":        {"longer", nil},
+	"extra":         {"value", []byte{2}},
+	"value":         {"value2", nil},
+	"zzz-unchanged": {"", nil},
+	"zzzz-added":    {"y", nil},
+}
+
+func checkDiff(t *testing.T, iter *DiffIter, expected []DiffEntry) {
+	var actual []DiffEntry
+	for iter.Next() {
+		actual = append(actual, iter.Entry())
+	}
+	if iter.Err() != nil {
+		t.Error("Unexpected error", iter.Err())
+	}
+	if len(actual) != len(expected) {
+		t.Fatal("Unexpected diff", actual)
+	}
+	for i, e := range expected {
+		a := actual[i]
+		if string(a.Key) != string(e.Key) || a.Type != e.Type ||
+			a.LengthChanged != e.LengthChanged || a.ExtraChanged != e.ExtraChanged ||
+			a.ValueChanged != e.ValueChanged {
+			t.Errorf("Unexpected diff entry %d, expected %+v, actual %+v", i, e, a)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	for _, checksums := range []bool{false, true} {
+		opts := BuilderOptions{Checksums: checksums}
+		a, err := buildReader(t, buildTableWithOptions(t, diffValuesA, opts))
+		if err != nil {
+			t.Fatal("Error building table", err)
+		}
+		b, err := buildReader(t, buildTableWithOptions(t, diffValuesB, opts))
+		if err != nil {
+			t.Fatal("Error building table", err)
+		}
+		if a.HasChecksums() != checksums {
+			t.Error("Unexpected HasChecksums()", a.HasChecksums())
+		}
+
+		checkDiff(t, Diff(a, b), []DiffEntry{
+			{Type: DiffAdded, Key: []byte("added")},
+			{Type: DiffChanged, Key: []byte("extra"), ExtraChanged: true},
+			{Type: DiffChanged, Key: []byte("length"), LengthChanged: true},
+			{Type: DiffRemoved, Key: []byte("removed")},
+			{Type: DiffRemoved, Key: []byte("zzz-removed-to")},
+			{Type: DiffAdded, Key: []byte("zzzz-added")},
+		})
+
+		checkDiff(t, DiffWithOptions(a, b, DiffOptions{CompareValues: true}), []DiffEntry{
+			{Type: DiffAdded, Key: []byte("added")},
+			{Type: DiffChanged, Key: []byte("extra"), ExtraChanged: true},
+			{Type: DiffChanged, Key: []byte("length"), LengthChanged: true, ValueChanged: true},
+			{Type: DiffRemoved, Key: []byte("removed")},
+			{Type: DiffChanged, Key: []byte("value"), ValueChanged: true},
+			{Type: DiffRemoved, Key: []byte("zzz-removed-to")},
+			{Type: DiffAdded, Key: []byte("zzzz-added")},
+		})
+
+		checkDiff(t, Diff(a, a), nil)
+	}
+}
@@ -0,0 +1,86 @@
+package sstable
+
+import (
+	"io"
+	"os"
+	"unsafe"
+)
+
+const (
+	// Alignment of offsets, lengths and buffers for direct I/O.
+	DirectIOAlignment = 4096
+
+	// Largest single read made by a DirectFile.
+	maxDirectRead = 1024 * 1024
+)
+
+// DirectFile is an io.ReaderAt which reads a file using direct I/O (O_DIRECT)
+// where supported, bypassing the page cache. This is most useful for tables
+// whose values are aligned (see BuilderOptions.ValueAlignment), since
+// unaligned reads must be expanded to aligned boundaries.
+type DirectFile struct {
+	f      *os.File
+	direct bool
+}
+
+var _ io.ReaderAt = (*DirectFile)(nil)
+
+// OpenDirect opens the named file for direct I/O. If the platform or
+// filesystem doesn't support direct I/O, the file is opened normally.
+func OpenDirect(name string) (*DirectFile, error) {
+	f, direct, err := openDirect(name)
+	if err != nil {
+		return nil, err
+	}
+	return &DirectFile{f: f, direct: direct}, nil
+}
+
+// Returns true if the file is being read with direct I/O.
+func (f *DirectFile) Direct() bool {
+	return f.direct
+}
+
+func (f *DirectFile) Close() error {
+	return f.f.Close()
+}
+
+func (f *DirectFile) ReadAt(p []byte, off int64) (int, error) {
+	if !f.direct {
+		return f.f.ReadAt(p, off)
+	}
+
+	var buf []byte
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		start := pos &^ (DirectIOAlignment - 1)
+		end := int64(alignUp(uint64(pos)+uint64(len(p)-n), DirectIOAlignment))
+		if end-start > maxDirectRead {
+			end = start + maxDirectRead
+		}
+		if len(buf) < int(end-start) {
+			buf = alignedBuffer(int(end - start))
+		}
+
+		m, err := f.f.ReadAt(buf[:end-start], start)
+		if skip := int(pos - start); m > skip {
+			n += copy(p[n:], buf[skip:m])
+		}
+		if err == io.EOF && n == len(p) {
+			break
+		} else if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Returns a buffer of length n, aligned for direct I/O.
+func alignedBuffer(n int) []byte {
+	buf := make([]byte, n+DirectIOAlignment)
+	skip := int(uintptr(unsafe.Pointer(&buf[0])) & (DirectIOAlignment - 1))
+	if skip > 0 {
+		skip = DirectIOAlignment - skip
+	}
+	return buf[skip : skip+n : skip+n]
+}
@@ -0,0 +1,17 @@
+package sstable
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func openDirect(name string) (*os.File, bool, error) {
+	f, err := os.OpenFile(name, os.O_RDONLY|syscall.O_DIRECT, 0)
+	if errors.Is(err, syscall.EINVAL) {
+		// Filesystem doesn't support O_DIRECT (i.e. tmpfs).
+		f, err = os.Open(name)
+		return f, false, err
+	}
+	return f, err == nil, err
+}
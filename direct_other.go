@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sstable
+
+import (
+	"os"
+)
+
+func openDirect(name string) (*os.File, bool, error) {
+	f, err := os.Open(name)
+	return f, false, err
+}
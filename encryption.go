@@ -0,0 +1,219 @@
+package sstable
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	pb "github.com/akmistry/simple-sstable/proto"
+)
+
+// KeyProvider protects the per-table data keys of encrypted tables, typically
+// by encrypting them with a master key held in a key management service.
+type KeyProvider interface {
+	// Wraps a newly generated data key for storage in the table header.
+	WrapKey(key []byte) ([]byte, error)
+
+	// Unwraps a data key previously wrapped by WrapKey.
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+const (
+	DefaultEncryptionChunkSize = 64 * 1024
+	MaxEncryptionChunkSize     = 16 * 1024 * 1024
+
+	dataKeySize = 32
+
+	// Nonce domains.
+	nonceDomainIndex = 0
+	nonceDomainValue = 1
+)
+
+var (
+	ErrNoKeyProvider    = errors.New("Table is encrypted, but no key provider given")
+	errDecryptionFailed = errors.New("Decryption failed")
+)
+
+// Encrypts and decrypts the index and values of a table. See pb.Encryption for
+// the format.
+type tableCipher struct {
+	aead      cipher.AEAD
+	chunkSize uint64
+
+	// *[]byte buffers used by readValue, of up to chunkSize plus overhead.
+	bufPool sync.Pool
+}
+
+func newTableCipher(key []byte, chunkSize int) (*tableCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &tableCipher{aead: aead, chunkSize: uint64(chunkSize)}, nil
+}
+
+// Generates a new data key, and returns it along with a cipher using it.
+func newDataKey(chunkSize int) ([]byte, *tableCipher) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		log.Panicln("Error generating data key", err)
+	}
+	c, err := newTableCipher(key, chunkSize)
+	if err != nil {
+		log.Panicln("Unexpected error creating cipher", err)
+	}
+	return key, c
+}
+
+func loadCipher(kp KeyProvider, info *pb.Encryption) (*tableCipher, error) {
+	if info.Cipher != pb.Encryption_AES_256_GCM {
+		return nil, fmt.Errorf("Unsupported cipher %v", info.Cipher)
+	} else if info.ChunkSize == 0 || info.ChunkSize > MaxEncryptionChunkSize {
+		return nil, fmt.Errorf("Invalid encryption chunk size %d", info.ChunkSize)
+	} else if kp == nil {
+		return nil, ErrNoKeyProvider
+	}
+	key, err := kp.UnwrapKey(info.WrappedKey)
+	if err != nil {
+		return nil, err
+	} else if len(key) != dataKeySize {
+		return nil, fmt.Errorf("Invalid data key length %d", len(key))
+	}
+	return newTableCipher(key, int(info.ChunkSize))
+}
+
+func (c *tableCipher) nonce(domain uint32, off uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.LittleEndian.PutUint32(nonce, domain)
+	binary.LittleEndian.PutUint64(nonce[4:], off)
+	return nonce
+}
+
+func (c *tableCipher) seal(dst, plaintext []byte, domain uint32, off uint64) []byte {
+	return c.aead.Seal(dst, c.nonce(domain, off), plaintext, nil)
+}
+
+func (c *tableCipher) open(dst, ciphertext []byte, domain uint32, off uint64) ([]byte, error) {
+	out, err := c.aead.Open(dst, c.nonce(domain, off), ciphertext, nil)
+	if err != nil {
+		return nil, errDecryptionFailed
+	}
+	return out, nil
+}
+
+// Returns the stored length of a value of the given length.
+func (c *tableCipher) storedLength(length uint64) uint64 {
+	chunks := (length + c.chunkSize - 1) / c.chunkSize
+	return length + chunks*uint64(c.aead.Overhead())
+}
+
+// Reads [off, off+len(p)) of a value stored at dataOff (relative to the data
+// section). p must not extend past the end of the value.
+func (c *tableCipher) readValue(read func(p []byte, off int64) (int, error), p []byte, off int64, dataOff uint64, length uint64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	overhead := uint64(c.aead.Overhead())
+	// Only the last chunk of a value is short, so the first chunk read is the
+	// largest.
+	maxChunkLen := length - uint64(off)/c.chunkSize*c.chunkSize
+	if maxChunkLen > c.chunkSize {
+		maxChunkLen = c.chunkSize
+	}
+	stored := c.getBuf(int(maxChunkLen + overhead))
+	defer c.bufPool.Put(stored)
+
+	n := 0
+	for n < len(p) {
+		pos := uint64(off) + uint64(n)
+		chunk := pos / c.chunkSize
+		chunkLen := length - chunk*c.chunkSize
+		if chunkLen > c.chunkSize {
+			chunkLen = c.chunkSize
+		}
+		chunkOff := chunk * (c.chunkSize + overhead)
+
+		buf := (*stored)[:chunkLen+overhead]
+		m, err := read(buf, int64(chunkOff))
+		if err == io.EOF && m == len(buf) {
+			err = nil
+		} else if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return n, err
+		}
+		// Decrypt in place.
+		plain, err := c.open(buf[:0], buf, nonceDomainValue, dataOff+chunkOff)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], plain[pos-chunk*c.chunkSize:])
+	}
+	return n, nil
+}
+
+// Returns a buffer of at least size bytes, which should be returned to
+// bufPool when no longer needed.
+func (c *tableCipher) getBuf(size int) *[]byte {
+	if buf, ok := c.bufPool.Get().(*[]byte); ok && cap(*buf) >= size {
+		return buf
+	}
+	buf := make([]byte, size)
+	return &buf
+}
+
+// Encrypts values written through it, in chunks.
+type encryptingWriter struct {
+	w   io.Writer
+	c   *tableCipher
+	buf []byte
+	out []byte
+	// Offset of the next chunk, relative to the data section.
+	pos uint64
+}
+
+func newEncryptingWriter(w io.Writer, c *tableCipher) *encryptingWriter {
+	return &encryptingWriter{
+		w:   w,
+		c:   c,
+		buf: make([]byte, 0, c.chunkSize),
+	}
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(w.buf[len(w.buf):cap(w.buf)], p[n:])
+		w.buf = w.buf[:len(w.buf)+c]
+		n += c
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Writes any partial chunk. Must be called at the end of each value.
+func (w *encryptingWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	w.out = w.c.seal(w.out[:0], w.buf, nonceDomainValue, w.pos)
+	w.buf = w.buf[:0]
+	w.pos += uint64(len(w.out))
+	_, err := w.w.Write(w.out)
+	return err
+}
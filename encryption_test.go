@@ -0,0 +1,157 @@
+package sstable
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// Wraps keys by XORing them with a mask.
+type testKeyProvider struct {
+	mask byte
+}
+
+func (p *testKeyProvider) WrapKey(key []byte) ([]byte, error) {
+	wrapped := []byte("wrapped:")
+	for _, b := range key {
+		wrapped = append(wrapped, b^p.mask)
+	}
+	return wrapped, nil
+}
+
+func (p *testKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	if !bytes.HasPrefix(wrapped, []byte("wrapped:")) {
+		return nil, errors.New("Invalid wrapped key")
+	}
+	var key []byte
+	for _, b := range wrapped[len("wrapped:"):] {
+		key = append(key, b^p.mask)
+	}
+	return key, nil
+}
+
+func TestEncryption(t *testing.T) {
+	entries := generateEntries(10)
+	kp := &testKeyProvider{mask: 0x5a}
+	buf := buildTableWithOptions(t, entries, BuilderOptions{KeyProvider: kp, EncryptionChunkSize: 64})
+	if bytes.Contains(buf, []byte("key00")) || bytes.Contains(buf, []byte(entries["key0009"].val)) {
+		t.Error("Plaintext found in encrypted table")
+	}
+
+	if _, err := Load(bytes.NewReader(buf)); err != ErrNoKeyProvider {
+		t.Error("Expected ErrNoKeyProvider, got", err)
+	}
+	if _, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{KeyProvider: &testKeyProvider{mask: 1}}); err == nil {
+		t.Error("Expected error loading with wrong key")
+	}
+
+	table, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{KeyProvider: kp})
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	if !table.IsEncrypted() {
+		t.Error("Table not encrypted")
+	}
+	checkTable(t, table, entries)
+
+	// Reads at every offset, crossing chunk boundaries.
+	value := entries["key0009"].val
+	r, err := table.GetReader([]byte("key0009"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for off := 0; off < len(value); off += 7 {
+		p := make([]byte, 70)
+		n, err := r.ReadAt(p, int64(off))
+		expected := value[off:]
+		if len(expected) > len(p) {
+			expected = expected[:len(p)]
+		}
+		if err != nil && err != io.EOF {
+			t.Error("Unexpected error", err)
+		} else if string(p[:n]) != expected {
+			t.Errorf("ReadAt(%d) = %q, expected %q", off, p[:n], expected)
+		}
+	}
+
+	report, err := table.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if !report.OK() {
+		t.Error("Unexpected problems", report.Problems)
+	} else if report.ValuesDecrypted != len(entries) {
+		t.Error("Unexpected decrypted count", report.ValuesDecrypted)
+	}
+}
+
+func TestEncryption_Corrupt(t *testing.T) {
+	entries := generateEntries(10)
+	kp := &testKeyProvider{mask: 0x5a}
+	buf := buildTableWithOptions(t, entries, BuilderOptions{KeyProvider: kp, EncryptionChunkSize: 64})
+	// Flip a bit in the last value.
+	buf[len(buf)-20] ^= 1
+
+	table, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{KeyProvider: kp})
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	if _, _, err := table.Get([]byte("key0009")); err == nil {
+		t.Error("Expected error reading corrupt value")
+	}
+	if _, _, err := table.Get([]byte("key0001")); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	report, err := table.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if report.NumProblems != 1 || !bytes.Equal(report.Problems[0].Key, []byte("key0009")) {
+		t.Error("Unexpected problems", report.Problems)
+	}
+}
+
+func TestEncryption_Empty(t *testing.T) {
+	kp := &testKeyProvider{mask: 0x5a}
+	buf := buildTableWithOptions(t, emptyTable, BuilderOptions{KeyProvider: kp})
+	table, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{KeyProvider: kp})
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	if table.NumKeys() != 0 {
+		t.Error("Unexpected keys", table.NumKeys())
+	}
+}
+
+func TestEncryption_Checksums(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic")
+		}
+	}()
+	NewBuilderWithOptions(io.Discard, nil, BuilderOptions{KeyProvider: &testKeyProvider{}, Checksums: true})
+}
+
+func TestEncryption_ReadAllocation(t *testing.T) {
+	entries := generateEntries(10)
+	kp := &testKeyProvider{mask: 0x5a}
+	buf := buildTableWithOptions(t, entries, BuilderOptions{KeyProvider: kp, EncryptionChunkSize: MaxEncryptionChunkSize})
+	table, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{KeyProvider: kp})
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+
+	// Buffers are sized to the value, not the (16MiB) chunk size.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 100; i++ {
+		if v, _, err := table.Get([]byte("key0001")); err != nil || string(v) != entries["key0001"].val {
+			t.Fatal("Unexpected value", v, err)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1024*1024 {
+		t.Error("Too much allocated", alloc)
+	}
+}
@@ -0,0 +1,71 @@
+package sstable
+
+import (
+	"bufio"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// A file being built. The table is written to a temporary file in the same
+// directory, which is renamed once the table is complete.
+type fileTarget struct {
+	f    *os.File
+	w    *bufio.Writer
+	path string
+}
+
+// NewFileBuilder returns a builder which writes a table to the named file.
+// The file only appears once Build succeeds, replacing any existing file.
+// If building fails or is aborted, the temporary file is removed.
+func NewFileBuilder(path string, vf ValueWriter, opts BuilderOptions) (*Builder, error) {
+	f, err := createTemp(path)
+	if err != nil {
+		return nil, err
+	}
+	target := &fileTarget{f: f, w: bufio.NewWriter(f), path: path}
+	b := NewBuilderWithOptions(target.w, vf, opts)
+	b.file = target
+	return b, nil
+}
+
+// Creates a new temporary file next to path. Unlike os.CreateTemp, the file is
+// created with mode 0666 (before umask), like os.Create, since it becomes the
+// table.
+func createTemp(path string) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	for i := 0; ; i++ {
+		name := prefix + strconv.FormatUint(uint64(rand.Uint32()), 10)
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) && i < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// Completes the file, if the table was built without error.
+func (t *fileTarget) finish(err error) error {
+	if err == nil {
+		err = t.w.Flush()
+	}
+	if err == nil {
+		err = t.f.Sync()
+	}
+	if cerr := t.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(t.f.Name(), t.path)
+	}
+	if err != nil {
+		os.Remove(t.f.Name())
+	}
+	return err
+}
+
+func (t *fileTarget) abort() {
+	t.f.Close()
+	os.Remove(t.f.Name())
+}
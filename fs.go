@@ -0,0 +1,188 @@
+package sstable
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+type tableFS struct {
+	t *Table
+}
+
+// FS returns a read-only filesystem view of the table. Keys are treated as
+// slash-separated paths, and directories are synthesised from key prefixes.
+// Keys which aren't valid paths (see fs.ValidPath) are not visible. If a key is
+// both a file and a prefix of other keys (i.e. "a" and "a/b"), it is treated as
+// a file, although the longer keys can still be opened.
+func (t *Table) FS() fs.FS {
+	return &tableFS{t: t}
+}
+
+var (
+	_ fs.StatFS     = (*tableFS)(nil)
+	_ fs.ReadFileFS = (*tableFS)(nil)
+)
+
+func (f *tableFS) Open(name string) (fs.File, error) {
+	info, i, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.dir {
+		return &tableDir{fs: f, info: info, prefix: dirPrefix(name), pos: i}, nil
+	}
+	r := f.t.valueReader(i)
+	return &tableFile{info: info, SectionReader: io.NewSectionReader(r, 0, r.Size())}, nil
+}
+
+func (f *tableFS) Stat(name string) (fs.FileInfo, error) {
+	info, _, err := f.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (f *tableFS) ReadFile(name string) ([]byte, error) {
+	info, _, err := f.stat("readfile", name)
+	if err != nil {
+		return nil, err
+	} else if info.dir {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errIsDir}
+	}
+	value, _, err := f.t.Get([]byte(name))
+	return value, err
+}
+
+var errIsDir = errors.New("Is a directory")
+
+// Returns the directory's key prefix.
+func dirPrefix(name string) string {
+	if name == "." {
+		return ""
+	}
+	return name + "/"
+}
+
+// Looks up name. For files, also returns the index of the entry. For
+// directories, returns the index of the first key in the directory.
+func (f *tableFS) stat(op, name string) (*fileInfo, int, error) {
+	if !fs.ValidPath(name) {
+		return nil, 0, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		if i, ok := f.t.index.find([]byte(name)); ok {
+			return &fileInfo{name: path.Base(name), size: int64(f.t.index.length(i))}, i, nil
+		}
+	}
+
+	prefix := []byte(dirPrefix(name))
+	i := f.t.index.search(prefix)
+	if name == "." || (i < f.t.index.len() && bytes.HasPrefix(f.t.index.key(i), prefix)) {
+		return &fileInfo{name: path.Base(name), dir: true}, i, nil
+	}
+	return nil, 0, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i *fileInfo) Name() string { return i.name }
+func (i *fileInfo) Size() int64  { return i.size }
+func (i *fileInfo) IsDir() bool  { return i.dir }
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (i *fileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i *fileInfo) Info() (fs.FileInfo, error) { return i, nil }
+func (i *fileInfo) ModTime() time.Time         { return time.Time{} }
+func (i *fileInfo) Sys() interface{}           { return nil }
+
+type tableFile struct {
+	*io.SectionReader
+	info *fileInfo
+}
+
+func (f *tableFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *tableFile) Close() error               { return nil }
+
+type tableDir struct {
+	fs     *tableFS
+	info   *fileInfo
+	prefix string
+
+	// Index of the next key to list.
+	pos int
+}
+
+func (d *tableDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *tableDir) Close() error               { return nil }
+
+func (d *tableDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errIsDir}
+}
+
+// Returns the smallest key greater than all keys with the given prefix, or nil
+// if there is no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := dup(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+func (d *tableDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	t := d.fs.t
+	var entries []fs.DirEntry
+	for (n <= 0 || len(entries) < n) && d.pos < t.index.len() {
+		key := t.index.key(d.pos)
+		if !strings.HasPrefix(string(key), d.prefix) {
+			d.pos = t.index.len()
+			break
+		}
+
+		rest := string(key[len(d.prefix):])
+		slash := strings.IndexByte(rest, '/')
+		if slash < 0 {
+			if fs.ValidPath(rest) {
+				entries = append(entries, &fileInfo{name: rest, size: int64(t.index.length(d.pos))})
+			}
+			d.pos++
+			continue
+		}
+
+		// Skip over the rest of the subdirectory.
+		child := rest[:slash]
+		end := prefixEnd([]byte(d.prefix + child + "/"))
+		if end == nil {
+			d.pos = t.index.len()
+		} else {
+			d.pos = t.index.search(end)
+		}
+		if fs.ValidPath(child) && !t.Has([]byte(d.prefix+child)) {
+			entries = append(entries, &fileInfo{name: child, dir: true})
+		}
+	}
+
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
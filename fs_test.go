@@ -0,0 +1,70 @@
+package sstable
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+var fsValues = map[string]testValuePair{
+	"README":           {"readme", nil},
+	"a/b/c.txt":        {"c", nil},
+	"a/b/d.txt":        {"dd", nil},
+	"a/e.txt":          {"", nil},
+	"a/f/g/h.txt":      {"hhh", nil},
+	"a0":               {"a0", nil},
+	"z/y":              {"zy", nil},
+	"invalid//path":    {"x", nil},
+	"/absolute":        {"x", nil},
+	"dotdot/../escape": {"x", nil},
+}
+
+func TestFS(t *testing.T) {
+	table, err := buildReader(t, buildTable(t, fsValues))
+	if err != nil {
+		t.Fatal("Error building table", err)
+	}
+	fsys := table.FS()
+
+	err = fstest.TestFS(fsys, "README", "a/b/c.txt", "a/b/d.txt", "a/e.txt", "a/f/g/h.txt", "a0", "z/y")
+	if err != nil {
+		t.Error(err)
+	}
+
+	entries, err := fs.ReadDir(fsys, "a")
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 3 || names[0] != "b" || names[1] != "e.txt" || names[2] != "f" {
+		t.Error("Unexpected entries", names)
+	}
+
+	info, err := fs.Stat(fsys, "a/b/d.txt")
+	if err != nil || info.Size() != 2 || info.IsDir() {
+		t.Error("Unexpected stat", info, err)
+	}
+	info, err = fs.Stat(fsys, "a/f")
+	if err != nil || !info.IsDir() {
+		t.Error("Unexpected stat", info, err)
+	}
+
+	f, err := fsys.Open("a/f/g/h.txt")
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	buf := make([]byte, 2)
+	if n, err := f.(io.ReaderAt).ReadAt(buf, 1); n != 2 || string(buf) != "hh" {
+		t.Error("Unexpected read", n, err)
+	}
+
+	for _, name := range []string{"a/b/x", "b", "a/b/c.txt/d"} {
+		if _, err := fsys.Open(name); err == nil {
+			t.Error("Expected error opening", name)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package sstable
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// Distribution of a set of sizes (in bytes).
+type SizeDistribution struct {
+	Count int
+	Total int64
+	Min   int64
+	Max   int64
+	Mean  float64
+
+	P50 int64
+	P90 int64
+	P99 int64
+
+	// Buckets[0] counts sizes of 0, and Buckets[i] counts sizes in the range
+	// [2^(i-1), 2^i).
+	Buckets []int
+}
+
+// Returns the inclusive range of sizes counted by Buckets[i].
+func BucketRange(i int) (lo, hi int64) {
+	if i == 0 {
+		return 0, 0
+	}
+	return 1 << (i - 1), 1<<i - 1
+}
+
+func newSizeDistribution(sizes []int64) SizeDistribution {
+	d := SizeDistribution{Count: len(sizes)}
+	if len(sizes) == 0 {
+		return d
+	}
+
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	d.Min = sizes[0]
+	d.Max = sizes[len(sizes)-1]
+	d.Buckets = make([]int, bits.Len64(uint64(d.Max))+1)
+	for _, s := range sizes {
+		d.Total += s
+		d.Buckets[bits.Len64(uint64(s))]++
+	}
+	d.Mean = float64(d.Total) / float64(len(sizes))
+	d.P50 = percentile(sizes, 50)
+	d.P90 = percentile(sizes, 90)
+	d.P99 = percentile(sizes, 99)
+	return d
+}
+
+// Returns the p-th percentile of sorted, using the nearest-rank method.
+func percentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+type TableHistogram struct {
+	KeySize   SizeDistribution
+	ValueSize SizeDistribution
+	ExtraSize SizeDistribution
+
+	// Number of leading bytes each key shares with the previous key.
+	SharedPrefix SizeDistribution
+}
+
+// Histogram computes the distributions of key, value and extra sizes, and of
+// prefixes shared between consecutive keys.
+func (t *Table) Histogram() TableHistogram {
+	n := t.index.len()
+	keySizes := make([]int64, n)
+	valueSizes := make([]int64, n)
+	extraSizes := make([]int64, n)
+	var shared []int64
+	if n > 1 {
+		shared = make([]int64, n-1)
+	}
+	for i := 0; i < n; i++ {
+		key := t.index.key(i)
+		keySizes[i] = int64(len(key))
+		valueSizes[i] = int64(t.index.length(i))
+		extraSizes[i] = int64(len(t.index.extra(i)))
+		if i > 0 {
+			shared[i-1] = int64(commonPrefix(t.index.key(i-1), key))
+		}
+	}
+
+	return TableHistogram{
+		KeySize:      newSizeDistribution(keySizes),
+		ValueSize:    newSizeDistribution(valueSizes),
+		ExtraSize:    newSizeDistribution(extraSizes),
+		SharedPrefix: newSizeDistribution(shared),
+	}
+}
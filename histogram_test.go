@@ -0,0 +1,59 @@
+package sstable
+
+import (
+	"testing"
+)
+
+func TestSizeDistribution(t *testing.T) {
+	var sizes []int64
+	for i := 100; i >= 0; i-- {
+		sizes = append(sizes, int64(i))
+	}
+	d := newSizeDistribution(sizes)
+	if d.Count != 101 || d.Min != 0 || d.Max != 100 || d.Total != 5050 || d.Mean != 50 {
+		t.Error("Unexpected distribution", d)
+	}
+	if d.P50 != 50 || d.P90 != 90 || d.P99 != 99 {
+		t.Error("Unexpected percentiles", d.P50, d.P90, d.P99)
+	}
+
+	// Buckets: [0], [1], [2,3], [4,7], ..., [64,127]
+	expected := []int{1, 1, 2, 4, 8, 16, 32, 37}
+	if len(d.Buckets) != len(expected) {
+		t.Fatal("Unexpected buckets", d.Buckets)
+	}
+	for i, c := range expected {
+		if d.Buckets[i] != c {
+			t.Error("Unexpected bucket count", i, c, d.Buckets[i])
+		}
+	}
+	if lo, hi := BucketRange(7); lo != 64 || hi != 127 {
+		t.Error("Unexpected bucket range", lo, hi)
+	}
+
+	d = newSizeDistribution(nil)
+	if d.Count != 0 || d.Buckets != nil {
+		t.Error("Unexpected distribution", d)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	table, err := buildReader(t, buildTable(t, testValues))
+	if err != nil {
+		t.Fatal("Error building table", err)
+	}
+
+	h := table.Histogram()
+	if h.KeySize.Count != 9 || h.KeySize.Max != 256 || h.KeySize.Min != 3 {
+		t.Error("Unexpected key size distribution", h.KeySize)
+	}
+	if h.ValueSize.Total != 39 || h.ValueSize.Min != 0 || h.ValueSize.Max != 11 {
+		t.Error("Unexpected value size distribution", h.ValueSize)
+	}
+	if h.ExtraSize.Total != 5 || h.ExtraSize.Buckets[0] != 8 {
+		t.Error("Unexpected extra size distribution", h.ExtraSize)
+	}
+	if h.SharedPrefix.Count != 8 || h.SharedPrefix.Max != 3 {
+		t.Error("Unexpected shared prefix distribution", h.SharedPrefix)
+	}
+}
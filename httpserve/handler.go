@@ -0,0 +1,185 @@
+// Package httpserve serves the contents of a table over HTTP.
+package httpserve
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	sstable "github.com/akmistry/simple-sstable"
+)
+
+const (
+	DefaultContentType = "application/octet-stream"
+	DefaultListLimit   = 1000
+)
+
+type Options struct {
+	// Header used to return an entry's extra data, base64 encoded. If empty,
+	// extra data is not returned.
+	ExtraHeader string
+
+	// Content type used when it can't be determined from the key's extension.
+	// If empty, DefaultContentType is used.
+	ContentType string
+
+	// Serve a JSON listing of keys for paths ending in "/".
+	Listing bool
+
+	// Maximum number of keys returned in a listing. If 0, DefaultListLimit is
+	// used.
+	ListLimit int
+
+	// Identifies the table in ETags for tables without checksums, which are
+	// based on value offsets. Should change whenever the table does (i.e. the
+	// file name or modification time). If empty, a hash of the table's keys,
+	// extra data and value locations is used, which only changes with the
+	// table's contents if the index does.
+	TableID string
+}
+
+// Handler serves values from a table. The URL path (without the leading "/")
+// is used as the key. Use http.StripPrefix to serve from a sub-path.
+type Handler struct {
+	t    *sstable.Table
+	opts Options
+}
+
+func NewHandler(t *sstable.Table, opts Options) *Handler {
+	if opts.ContentType == "" {
+		opts.ContentType = DefaultContentType
+	}
+	if opts.ListLimit <= 0 {
+		opts.ListLimit = DefaultListLimit
+	}
+	if opts.TableID == "" && !t.HasChecksums() {
+		opts.TableID = tableID(t)
+	}
+	return &Handler{t: t, opts: opts}
+}
+
+// Returns a hash of the table's index.
+func tableID(t *sstable.Table) string {
+	h := fnv.New64a()
+	var buf [binary.MaxVarintLen64]byte
+	writeInt := func(v int64) {
+		h.Write(buf[:binary.PutVarint(buf[:], v)])
+	}
+	for iter := t.Seek(nil); iter.Valid(); iter.Next() {
+		vr := iter.Reader()
+		writeInt(int64(len(iter.Key())))
+		h.Write(iter.Key())
+		writeInt(int64(len(vr.Extra())))
+		h.Write(vr.Extra())
+		writeInt(vr.Offset())
+		writeInt(vr.Size())
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if h.opts.Listing && (key == "" || strings.HasSuffix(key, "/")) {
+		h.serveList(w, r, key)
+		return
+	}
+
+	vr, err := h.t.GetReader([]byte(key))
+	if err == sstable.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("ETag", h.etag(vr))
+	ct := mime.TypeByExtension(path.Ext(key))
+	if ct == "" {
+		ct = h.opts.ContentType
+	}
+	header.Set("Content-Type", ct)
+	if h.opts.ExtraHeader != "" && len(vr.Extra()) > 0 {
+		header.Set(h.opts.ExtraHeader, base64.StdEncoding.EncodeToString(vr.Extra()))
+	}
+
+	// ServeContent handles HEAD, Range and conditional requests.
+	content := io.NewSectionReader(&contextReaderAt{ctx: r.Context(), vr: vr}, 0, vr.Size())
+	http.ServeContent(w, r, key, time.Time{}, content)
+}
+
+func (h *Handler) etag(vr *sstable.ValueReader) string {
+	if sum, ok := vr.Checksum(); ok {
+		return fmt.Sprintf(`"%08x-%x"`, sum, vr.Size())
+	}
+	return strconv.Quote(fmt.Sprintf("%s-%x-%x", h.opts.TableID, vr.Offset(), vr.Size()))
+}
+
+// Propagates the request context to value reads.
+type contextReaderAt struct {
+	ctx context.Context
+	vr  *sstable.ValueReader
+}
+
+func (r *contextReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return r.vr.ReadAtContext(r.ctx, p, off)
+}
+
+type listEntry struct {
+	Key   string `json:"key"`
+	Size  int64  `json:"size"`
+	Extra []byte `json:"extra,omitempty"`
+}
+
+type listResponse struct {
+	Prefix string      `json:"prefix"`
+	Keys   []listEntry `json:"keys"`
+	// If set, more keys are available by listing with ?after=Next.
+	Next string `json:"next,omitempty"`
+}
+
+// Serves keys with the given prefix, starting after the "after" query
+// parameter.
+func (h *Handler) serveList(w http.ResponseWriter, r *http.Request, prefix string) {
+	resp := listResponse{Prefix: prefix, Keys: []listEntry{}}
+	start := prefix
+	after := r.URL.Query().Get("after")
+	if after > start {
+		// Smallest key greater than after.
+		start = after + "\x00"
+	}
+
+	for iter := h.t.Seek([]byte(start)); iter.Valid(); iter.Next() {
+		key := string(iter.Key())
+		if !strings.HasPrefix(key, prefix) {
+			break
+		} else if len(resp.Keys) == h.opts.ListLimit {
+			resp.Next = resp.Keys[len(resp.Keys)-1].Key
+			break
+		}
+		resp.Keys = append(resp.Keys, listEntry{Key: key, Size: iter.ValueSize(), Extra: iter.Extra()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	json.NewEncoder(w).Encode(&resp)
+}
@@ -0,0 +1,174 @@
+package httpserve
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sstable "github.com/akmistry/simple-sstable"
+)
+
+var testValues = []struct {
+	key, value string
+	extra      []byte
+}{
+	{"dir/a.txt", "hello world", []byte{1, 2}},
+	{"dir/b", "bbb", nil},
+	{"dir/c", "", nil},
+	{"other", "other value", nil},
+}
+
+func buildTable(t *testing.T, opts sstable.BuilderOptions) *sstable.Table {
+	values := make(map[string]string)
+	buf := new(bytes.Buffer)
+	b := sstable.NewBuilderWithOptions(buf, func(key []byte, w io.Writer) (int, error) {
+		return w.Write([]byte(values[string(key)]))
+	}, opts)
+	for _, v := range testValues {
+		values[v.key] = v.value
+		b.Add([]byte(v.key), uint64(len(v.value)), v.extra)
+	}
+	if err := b.Build(); err != nil {
+		t.Fatal("Error building table", err)
+	}
+	table, err := sstable.Load(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	return table
+}
+
+func get(t *testing.T, srv *httptest.Server, method, path string, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestHandler(t *testing.T) {
+	for _, checksums := range []bool{false, true} {
+		table := buildTable(t, sstable.BuilderOptions{Checksums: checksums})
+		srv := httptest.NewServer(NewHandler(table, Options{ExtraHeader: "X-Extra", Listing: true, TableID: "test"}))
+		defer srv.Close()
+
+		resp, body := get(t, srv, "GET", "/dir/a.txt", nil)
+		if resp.StatusCode != http.StatusOK || body != "hello world" {
+			t.Error("Unexpected response", resp.Status, body)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+			t.Error("Unexpected content type", ct)
+		}
+		if extra := resp.Header.Get("X-Extra"); extra != "AQI=" {
+			t.Error("Unexpected extra", extra)
+		}
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			t.Error("Missing ETag")
+		}
+
+		resp, body = get(t, srv, "GET", "/dir/a.txt", http.Header{"Range": {"bytes=6-"}})
+		if resp.StatusCode != http.StatusPartialContent || body != "world" {
+			t.Error("Unexpected response", resp.Status, body)
+		}
+
+		resp, _ = get(t, srv, "GET", "/dir/a.txt", http.Header{"If-None-Match": {etag}})
+		if resp.StatusCode != http.StatusNotModified {
+			t.Error("Unexpected response", resp.Status)
+		}
+
+		resp, body = get(t, srv, "HEAD", "/dir/b", nil)
+		if resp.StatusCode != http.StatusOK || body != "" || resp.ContentLength != 3 {
+			t.Error("Unexpected response", resp.Status, body, resp.ContentLength)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != DefaultContentType {
+			t.Error("Unexpected content type", ct)
+		}
+
+		resp, body = get(t, srv, "GET", "/dir/c", nil)
+		if resp.StatusCode != http.StatusOK || body != "" {
+			t.Error("Unexpected response", resp.Status, body)
+		}
+
+		resp, _ = get(t, srv, "GET", "/missing", nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Error("Unexpected response", resp.Status)
+		}
+
+		resp, _ = get(t, srv, "POST", "/dir/b", nil)
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Error("Unexpected response", resp.Status)
+		}
+	}
+}
+
+func TestHandler_DefaultTableID(t *testing.T) {
+	// The value for "a" differs, but has the same offset and size in both
+	// tables.
+	var etags []string
+	for i, entries := range [][2]string{{"a", "b"}, {"a", "c"}} {
+		buf := new(bytes.Buffer)
+		b := sstable.NewBuilder(buf, func(key []byte, w io.Writer) (int, error) {
+			return w.Write([]byte{key[0] + byte(i)})
+		})
+		for _, k := range entries {
+			if err := b.Add([]byte(k), 1, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := b.Build(); err != nil {
+			t.Fatal("Error building table", err)
+		}
+		table, err := sstable.Load(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		}
+		srv := httptest.NewServer(NewHandler(table, Options{}))
+		defer srv.Close()
+		resp, _ := get(t, srv, "GET", "/a", nil)
+		etags = append(etags, resp.Header.Get("ETag"))
+	}
+	if etags[0] == "" || etags[0] == etags[1] {
+		t.Error("Expected distinct ETags", etags)
+	}
+}
+
+func TestHandler_List(t *testing.T) {
+	table := buildTable(t, sstable.BuilderOptions{})
+	srv := httptest.NewServer(NewHandler(table, Options{Listing: true, ListLimit: 2}))
+	defer srv.Close()
+
+	var list listResponse
+	_, body := get(t, srv, "GET", "/dir/", nil)
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatal("Error decoding response", err, body)
+	}
+	if len(list.Keys) != 2 || list.Keys[0].Key != "dir/a.txt" || list.Keys[0].Size != 11 ||
+		list.Keys[1].Key != "dir/b" || list.Next != "dir/b" {
+		t.Error("Unexpected listing", list)
+	}
+
+	list = listResponse{}
+	_, body = get(t, srv, "GET", "/dir/?after=dir/b", nil)
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatal("Error decoding response", err, body)
+	}
+	if len(list.Keys) != 1 || list.Keys[0].Key != "dir/c" || list.Next != "" {
+		t.Error("Unexpected listing", list)
+	}
+}
@@ -0,0 +1,265 @@
+package sstable
+
+import (
+	"bytes"
+	"math"
+	"sort"
+)
+
+// Number of entries between restart points in the in-memory index.
+const memIndexRestartInterval = 16
+
+// In-memory index, stored as flat arrays to avoid per-entry allocations. Keys
+// are prefix compressed: entry i's key is the first shared[i] bytes of entry
+// i-1's key, followed by keys[keyEnds[i-1]:keyEnds[i]] (with keyEnds[-1] ==
+// 0). Entries with no shared prefix, which include a restart point every
+// memIndexRestartInterval entries, store their whole key. Extra data is stored
+// like keys, without compression.
+type index struct {
+	keys    []byte
+	keyEnds []int
+	shared  []uint16
+
+	// Entries which are restart points, in order.
+	restarts []int
+
+	// nil if no entry has extra data.
+	extras    []byte
+	extraEnds []int
+
+	// nil for key sets, which have no values.
+	offsets []uint64
+	lengths []uint64
+
+	// Last key added, until the index is compacted.
+	last []byte
+}
+
+func newIndex(numEntries int) *index {
+	return &index{
+		keyEnds: make([]int, 0, numEntries),
+		shared:  make([]uint16, 0, numEntries),
+		offsets: make([]uint64, 0, numEntries),
+		lengths: make([]uint64, 0, numEntries),
+	}
+}
+
+// Returns an index for a key set, which doesn't store offsets and lengths.
+func newKeyIndex(numEntries int) *index {
+	return &index{
+		keyEnds: make([]int, 0, numEntries),
+		shared:  make([]uint16, 0, numEntries),
+	}
+}
+
+func (x *index) len() int {
+	return len(x.keyEnds)
+}
+
+// Adds an entry. Keys must be added in increasing order.
+func (x *index) add(key, extra []byte, offset, length uint64) {
+	shared := 0
+	if len(x.keyEnds)%memIndexRestartInterval == 0 {
+		x.restarts = append(x.restarts, len(x.keyEnds))
+	} else {
+		shared = commonPrefix(x.last, key)
+		if shared > math.MaxUint16 {
+			shared = math.MaxUint16
+		}
+	}
+	x.last = append(x.last[:0], key...)
+	x.keys = append(x.keys, key[shared:]...)
+	x.keyEnds = append(x.keyEnds, len(x.keys))
+	x.shared = append(x.shared, uint16(shared))
+	if len(extra) > 0 && x.extraEnds == nil {
+		x.extraEnds = make([]int, len(x.keyEnds)-1, cap(x.keyEnds))
+	}
+	if x.extraEnds != nil {
+		x.extras = append(x.extras, extra...)
+		x.extraEnds = append(x.extraEnds, len(x.extras))
+	}
+	if x.offsets != nil {
+		x.offsets = append(x.offsets, offset)
+		x.lengths = append(x.lengths, length)
+	}
+}
+
+// Concatenates the given indexes into a single index.
+func mergeIndexes(parts []*index) *index {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	var numEntries, keysLen, extrasLen int
+	hasExtras := false
+	for _, p := range parts {
+		numEntries += p.len()
+		keysLen += len(p.keys)
+		extrasLen += len(p.extras)
+		hasExtras = hasExtras || p.extraEnds != nil
+	}
+
+	x := newIndex(numEntries)
+	if parts[0].offsets == nil {
+		x = newKeyIndex(numEntries)
+	}
+	x.keys = make([]byte, 0, keysLen)
+	if hasExtras {
+		x.extras = make([]byte, 0, extrasLen)
+		x.extraEnds = make([]int, 0, numEntries)
+	}
+	for _, p := range parts {
+		keyBase := len(x.keys)
+		x.keys = append(x.keys, p.keys...)
+		for _, end := range p.keyEnds {
+			x.keyEnds = append(x.keyEnds, keyBase+end)
+		}
+		x.shared = append(x.shared, p.shared...)
+		// Each part starts with a restart point, so keys can still be decoded
+		// from the part's restarts.
+		entryBase := x.len() - p.len()
+		for _, r := range p.restarts {
+			x.restarts = append(x.restarts, entryBase+r)
+		}
+		if hasExtras {
+			extraBase := len(x.extras)
+			x.extras = append(x.extras, p.extras...)
+			for i := 0; i < p.len(); i++ {
+				end := 0
+				if p.extraEnds != nil {
+					end = p.extraEnds[i]
+				}
+				x.extraEnds = append(x.extraEnds, extraBase+end)
+			}
+		}
+		x.offsets = append(x.offsets, p.offsets...)
+		x.lengths = append(x.lengths, p.lengths...)
+	}
+	return x
+}
+
+// Releases excess capacity left over from building the index.
+func (x *index) compact() {
+	x.last = nil
+	if cap(x.keys) > len(x.keys) {
+		x.keys = dup(x.keys)
+	}
+	if cap(x.extras) > len(x.extras) {
+		x.extras = dup(x.extras)
+	}
+	if cap(x.restarts) > len(x.restarts) {
+		x.restarts = append([]int(nil), x.restarts...)
+	}
+}
+
+// Returns the stored part of entry i's key.
+func (x *index) suffix(i int) []byte {
+	start := 0
+	if i > 0 {
+		start = x.keyEnds[i-1]
+	}
+	end := x.keyEnds[i]
+	return x.keys[start:end:end]
+}
+
+// Returns entry i's key. Unless the whole key is stored, the key is decoded
+// into a new slice.
+func (x *index) key(i int) []byte {
+	if x.shared[i] == 0 {
+		return x.suffix(i)
+	}
+	// Decode from the nearest preceding entry which stores its whole key.
+	j := i
+	for x.shared[j] != 0 {
+		j--
+	}
+	key := append([]byte(nil), x.suffix(j)...)
+	for j++; j <= i; j++ {
+		key = append(key[:x.shared[j]], x.suffix(j)...)
+	}
+	return key
+}
+
+// Returns the length of entry i's key.
+func (x *index) keyLen(i int) int {
+	return int(x.shared[i]) + len(x.suffix(i))
+}
+
+func (x *index) extra(i int) []byte {
+	if x.extraEnds == nil {
+		return nil
+	}
+	start := 0
+	if i > 0 {
+		start = x.extraEnds[i-1]
+	}
+	end := x.extraEnds[i]
+	if start == end {
+		return nil
+	}
+	return x.extras[start:end:end]
+}
+
+func (x *index) offset(i int) uint64 {
+	if x.offsets == nil {
+		return 0
+	}
+	return x.offsets[i]
+}
+
+func (x *index) length(i int) uint64 {
+	if x.lengths == nil {
+		return 0
+	}
+	return x.lengths[i]
+}
+
+// Returns the index of the first entry with a key >= key, or len() if no such
+// entry exists.
+func (x *index) search(key []byte) int {
+	i, _ := x.lookup(key)
+	return i
+}
+
+// Returns the index of the entry with the given key.
+func (x *index) find(key []byte) (int, bool) {
+	return x.lookup(key)
+}
+
+// Returns the index of the first entry with a key >= key, and whether its key
+// is equal to key. Restart points are binary searched, and then the block
+// before the first restart point >= key is scanned.
+func (x *index) lookup(key []byte) (int, bool) {
+	r := sort.Search(len(x.restarts), func(j int) bool {
+		return bytes.Compare(key, x.suffix(x.restarts[j])) <= 0
+	})
+	if r > 0 {
+		end := x.len()
+		if r < len(x.restarts) {
+			end = x.restarts[r]
+		}
+		// The key is after the restart point's key.
+		var buf [256]byte
+		cur := append(buf[:0], x.suffix(x.restarts[r-1])...)
+		for i := x.restarts[r-1] + 1; i < end; i++ {
+			cur = append(cur[:x.shared[i]], x.suffix(i)...)
+			if c := bytes.Compare(key, cur); c <= 0 {
+				return i, c == 0
+			}
+		}
+	}
+	if r == len(x.restarts) {
+		return x.len(), false
+	}
+	i := x.restarts[r]
+	return i, bytes.Equal(key, x.suffix(i))
+}
+
+// Approximate number of bytes of memory used by the index.
+func (x *index) memSize() int64 {
+	const intSize = 8
+	return int64(cap(x.keys)) + int64(cap(x.extras)) +
+		int64(cap(x.keyEnds)+cap(x.extraEnds)+cap(x.restarts))*intSize +
+		int64(cap(x.shared))*2 +
+		int64(cap(x.offsets)+cap(x.lengths))*8
+}
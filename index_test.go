@@ -0,0 +1,113 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	x := newIndex(0)
+	x.add([]byte("a"), nil, 0, 1)
+	x.add([]byte("bb"), []byte{1, 2}, 1, 2)
+	x.add([]byte("ccc"), nil, 3, 3)
+	x.add([]byte("dddd"), []byte{3}, 6, 4)
+	x.compact()
+
+	expectedExtras := [][]byte{nil, {1, 2}, nil, {3}}
+	for i, k := range []string{"a", "bb", "ccc", "dddd"} {
+		if string(x.key(i)) != k {
+			t.Error("Incorrect key", k, x.key(i))
+		}
+		if !bytes.Equal(x.extra(i), expectedExtras[i]) {
+			t.Error("Incorrect extra", expectedExtras[i], x.extra(i))
+		}
+		if x.length(i) != uint64(i+1) {
+			t.Error("Incorrect length", i+1, x.length(i))
+		}
+
+		j, ok := x.find([]byte(k))
+		if !ok || j != i {
+			t.Error("Unexpected find result", k, j, ok)
+		}
+	}
+
+	// Appending to a returned key must not overwrite the next key.
+	_ = append(x.key(0), 'z')
+	if string(x.key(1)) != "bb" {
+		t.Error("Key modified by append", x.key(1))
+	}
+
+	if i, ok := x.find([]byte("b")); ok || i != 1 {
+		t.Error("Unexpected find result", i, ok)
+	}
+	if i := x.search([]byte("e")); i != x.len() {
+		t.Error("Unexpected search result", i)
+	}
+	if x.memSize() <= 0 {
+		t.Error("Unexpected memory size", x.memSize())
+	}
+}
+
+func TestIndex_PrefixCompressed(t *testing.T) {
+	prefix := strings.Repeat("/long/path", 50)
+	var keys []string
+	for i := 0; i < 100; i++ {
+		keys = append(keys, fmt.Sprintf("%s/%03d", prefix, i*2))
+	}
+	check := func(x *index) {
+		if x.len() != len(keys) {
+			t.Fatal("Unexpected length", x.len())
+		}
+		for i, k := range keys {
+			if string(x.key(i)) != k {
+				t.Error("Incorrect key", k, string(x.key(i)))
+			}
+			if x.keyLen(i) != len(k) {
+				t.Error("Incorrect key length", len(k), x.keyLen(i))
+			}
+			if j, ok := x.find([]byte(k)); !ok || j != i {
+				t.Error("Unexpected find result", k, j, ok)
+			}
+			// Keys between entries.
+			if j, ok := x.find([]byte(fmt.Sprintf("%s/%03d", prefix, i*2-1))); ok || j != i {
+				t.Error("Unexpected find result", i*2-1, j, ok)
+			}
+		}
+		if i := x.search([]byte(prefix + "/999")); i != x.len() {
+			t.Error("Unexpected search result", i)
+		}
+		if i := x.search(nil); i != 0 {
+			t.Error("Unexpected search result", i)
+		}
+	}
+
+	x := newIndex(0)
+	for i, k := range keys {
+		x.add([]byte(k), nil, uint64(i), 1)
+	}
+	x.compact()
+	check(x)
+	// Only restart points store the whole key.
+	if len(x.keys) > (len(keys)/memIndexRestartInterval+1)*len(keys[0])+len(keys)*3 {
+		t.Error("Keys not prefix compressed", len(x.keys))
+	}
+
+	// Merging parts which don't end on an in-memory restart point.
+	var parts []*index
+	for _, n := range []int{5, 40, 55} {
+		p := newIndex(0)
+		start := 0
+		for _, q := range parts {
+			start += q.len()
+		}
+		for i := start; i < start+n; i++ {
+			p.add([]byte(keys[i]), nil, uint64(i), 1)
+		}
+		parts = append(parts, p)
+	}
+	x = mergeIndexes(parts)
+	x.compact()
+	check(x)
+}
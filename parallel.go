@@ -0,0 +1,148 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"runtime"
+	"sync"
+)
+
+// Writes sequentially to an io.WriterAt, starting at off.
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
+
+// A value to be written by a worker, and the start of any padding before it,
+// relative to the data section.
+type valueJob struct {
+	i       int
+	padFrom uint64
+}
+
+// BuildAt writes the table to w, calling the ValueWriter for up to
+// concurrency values at once. The ValueWriter must be safe for concurrent use.
+// If concurrency is 0, GOMAXPROCS is used. The table is identical to one
+// written by Build, and the io.Writer given to the builder is unused. Returns
+// ErrBuilderDone if the table has already been built or aborted.
+func (b *Builder) BuildAt(w io.WriterAt, concurrency int) error {
+	if b.done {
+		return ErrBuilderDone
+	} else if b.file != nil {
+		log.Panicln("BuildAt can't be used with a file builder")
+	} else if concurrency < 0 {
+		log.Panicf("Invalid concurrency %d", concurrency)
+	} else if concurrency == 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	b.done = true
+
+	headerBuf, index, dataOffset, err := b.encodeHeader()
+	if err != nil {
+		return err
+	}
+	if err := writeHeader(&offsetWriter{w: w}, headerBuf, index, dataOffset); err != nil {
+		return err
+	}
+
+	var checksums []byte
+	if b.checksums {
+		checksums = make([]byte, 4*len(b.keys))
+	}
+
+	jobs := make(chan valueJob)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				crc, jobErr := b.writeValueAt(w, dataOffset, job)
+				if jobErr != nil {
+					stopOnce.Do(func() {
+						err = jobErr
+						close(stop)
+					})
+					continue
+				}
+				if checksums != nil {
+					binary.LittleEndian.PutUint32(checksums[4*job.i:], crc)
+				}
+			}
+		}()
+	}
+
+	var pos uint64
+dispatch:
+	for i, pair := range b.keys {
+		if pair.sameAs > 0 || pair.length == 0 {
+			continue
+		}
+		select {
+		case jobs <- valueJob{i: i, padFrom: pos}:
+		case <-stop:
+			break dispatch
+		}
+		pos = pair.offset + b.storedLength(pair.length)
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+
+	if checksums == nil {
+		return nil
+	}
+	for i, pair := range b.keys {
+		if pair.sameAs > 0 {
+			j := 4 * (pair.sameAs - 1)
+			copy(checksums[4*i:4*i+4], checksums[j:j+4])
+		}
+	}
+	_, err = w.WriteAt(checksums, int64(dataOffset+b.valuePos))
+	return err
+}
+
+// Writes a value, and any padding before it, and returns its checksum if
+// checksums are enabled.
+func (b *Builder) writeValueAt(w io.WriterAt, dataOffset uint64, job valueJob) (uint32, error) {
+	pair := &b.keys[job.i]
+	ow := &offsetWriter{w: w, off: int64(dataOffset + job.padFrom)}
+	if err := writeZeros(ow, pair.offset-job.padFrom); err != nil {
+		return 0, err
+	}
+
+	var vw io.Writer = ow
+	var cw *checksumWriter
+	var ew *encryptingWriter
+	if b.cipher != nil {
+		ew = newEncryptingWriter(ow, b.cipher)
+		ew.pos = pair.offset
+		vw = ew
+	} else if b.checksums {
+		cw = &checksumWriter{w: ow}
+		vw = cw
+	}
+	n, err := b.vf(pair.key, vw)
+	if err != nil {
+		return 0, err
+	} else if uint64(n) != pair.length {
+		log.Panicf("Unexpected value write length %d, expected %d", n, pair.length)
+	}
+	if ew != nil {
+		return 0, ew.flush()
+	} else if cw != nil {
+		return cw.crc, nil
+	}
+	return 0, nil
+}
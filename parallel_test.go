@@ -0,0 +1,97 @@
+package sstable
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// An in-memory io.WriterAt.
+type memWriterAt struct {
+	lock sync.Mutex
+	buf  []byte
+}
+
+func newMemWriterAt() *memWriterAt {
+	return &memWriterAt{}
+}
+
+func (w *memWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	return copy(w.buf[off:], p), nil
+}
+
+func buildTableAt(t *testing.T, entries map[string]testValuePair, opts BuilderOptions, concurrency int) []byte {
+	b := NewBuilderWithOptions(nil, testValueWriter(entries), opts)
+	addTestEntries(t, b, entries)
+	w := newMemWriterAt()
+	if err := b.BuildAt(w, concurrency); err != nil {
+		t.Fatal(err)
+	}
+	return w.buf
+}
+
+func TestBuildAt(t *testing.T) {
+	entries := generateEntries(500)
+	for _, opts := range []BuilderOptions{
+		{},
+		{Checksums: true},
+		{Dedup: true, Checksums: true},
+		{ValueAlignment: 64, AlignmentThreshold: 100, Checksums: true},
+		{Dedup: true, ValueAlignment: 512},
+	} {
+		expected := buildTableWithOptions(t, entries, opts)
+		for _, concurrency := range []int{0, 1, 7} {
+			if buf := buildTableAt(t, entries, opts, concurrency); !bytes.Equal(buf, expected) {
+				t.Errorf("Table differs from Build, opts %+v concurrency %d", opts, concurrency)
+			}
+		}
+	}
+}
+
+func TestBuildAt_Encrypted(t *testing.T) {
+	entries := generateEntries(500)
+	kp := &testKeyProvider{mask: 0x11}
+	for _, opts := range []BuilderOptions{
+		{KeyProvider: kp, EncryptionChunkSize: 32},
+		{KeyProvider: kp, Dedup: true, ValueAlignment: 64},
+	} {
+		buf := buildTableAt(t, entries, opts, 4)
+		table, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{KeyProvider: kp})
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		}
+		checkTable(t, table, entries)
+	}
+}
+
+func TestBuildAt_Empty(t *testing.T) {
+	if buf := buildTableAt(t, emptyTable, BuilderOptions{Checksums: true}, 2); !bytes.Equal(buf, buildTableWithOptions(t, emptyTable, BuilderOptions{Checksums: true})) {
+		t.Error("Table differs from Build")
+	}
+}
+
+func TestBuildAt_Error(t *testing.T) {
+	entries := generateEntries(500)
+	errValue := errors.New("value error")
+	vf := func(key []byte, w io.Writer) (int, error) {
+		if string(key) == "key0123" {
+			return 0, errValue
+		}
+		return w.Write([]byte(entries[string(key)].val))
+	}
+	b := NewBuilder(nil, vf)
+	addTestEntries(t, b, entries)
+	if err := b.BuildAt(newMemWriterAt(), 4); err != errValue {
+		t.Error("Expected value error, got", err)
+	}
+	if err := b.BuildAt(newMemWriterAt(), 4); err != ErrBuilderDone {
+		t.Error("Expected ErrBuilderDone, got", err)
+	}
+}
@@ -11,6 +11,7 @@ It is generated from these files:
 It has these top-level messages:
 	TableHeader
 	IndexEntry
+	Encryption
 */
 package proto
 
@@ -50,15 +51,81 @@ func (x TableHeader_Compression) String() string {
 }
 func (TableHeader_Compression) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 0} }
 
+type TableHeader_Checksum int32
+
+const (
+	TableHeader_NO_CHECKSUM TableHeader_Checksum = 0
+	// CRC-32 using the Castagnoli polynomial.
+	TableHeader_CRC32C TableHeader_Checksum = 1
+)
+
+var TableHeader_Checksum_name = map[int32]string{
+	0: "NO_CHECKSUM",
+	1: "CRC32C",
+}
+var TableHeader_Checksum_value = map[string]int32{
+	"NO_CHECKSUM": 0,
+	"CRC32C":      1,
+}
+
+func (x TableHeader_Checksum) String() string {
+	return proto1.EnumName(TableHeader_Checksum_name, int32(x))
+}
+func (TableHeader_Checksum) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 1} }
+
+type Encryption_Cipher int32
+
+const (
+	Encryption_NONE        Encryption_Cipher = 0
+	Encryption_AES_256_GCM Encryption_Cipher = 1
+)
+
+var Encryption_Cipher_name = map[int32]string{
+	0: "NONE",
+	1: "AES_256_GCM",
+}
+var Encryption_Cipher_value = map[string]int32{
+	"NONE":        0,
+	"AES_256_GCM": 1,
+}
+
+func (x Encryption_Cipher) String() string {
+	return proto1.EnumName(Encryption_Cipher_name, int32(x))
+}
+func (Encryption_Cipher) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2, 0} }
+
 type TableHeader struct {
-	// Verison number. MUST be 1.
+	// Verison number. MUST be 1, 2 or 3. Version 2 allows 64-bit lengths.
+	// Version 3 is used for key sets.
 	Version uint32 `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
 	// Compression used for the index.
 	IndexCompression TableHeader_Compression `protobuf:"varint,2,opt,name=index_compression,json=indexCompression,enum=proto.TableHeader_Compression" json:"index_compression,omitempty"`
 	// Length of the index data.
-	IndexLength uint32 `protobuf:"varint,3,opt,name=index_length,json=indexLength" json:"index_length,omitempty"`
+	IndexLength uint64 `protobuf:"varint,3,opt,name=index_length,json=indexLength" json:"index_length,omitempty"`
 	// Number of index entries.
-	IndexEntries uint32 `protobuf:"varint,4,opt,name=index_entries,json=indexEntries" json:"index_entries,omitempty"`
+	IndexEntries uint64 `protobuf:"varint,4,opt,name=index_entries,json=indexEntries" json:"index_entries,omitempty"`
+	// Maximum key length. If 0, keys are at most 256 bytes.
+	MaxKeyLength uint32 `protobuf:"varint,5,opt,name=max_key_length,json=maxKeyLength" json:"max_key_length,omitempty"`
+	// Offsets (relative to the start of the index) of restart entries, which
+	// have a shared_prefix of 0. Entry i * index_restart_interval begins at
+	// index_restarts[i]. Allows the index to be decoded in parallel.
+	IndexRestarts []uint64 `protobuf:"varint,6,rep,packed,name=index_restarts,json=indexRestarts" json:"index_restarts,omitempty"`
+	// Number of entries between restarts.
+	IndexRestartInterval uint32 `protobuf:"varint,7,opt,name=index_restart_interval,json=indexRestartInterval" json:"index_restart_interval,omitempty"`
+	// Checksum used for values.
+	ValueChecksum TableHeader_Checksum `protobuf:"varint,8,opt,name=value_checksum,json=valueChecksum,enum=proto.TableHeader_Checksum" json:"value_checksum,omitempty"`
+	// Length of the value data. May be 0 for version 1 tables.
+	DataLength uint64 `protobuf:"varint,9,opt,name=data_length,json=dataLength" json:"data_length,omitempty"`
+	// Encryption of the index and values. Not set for unencrypted tables.
+	Encryption *Encryption `protobuf:"bytes,10,opt,name=encryption" json:"encryption,omitempty"`
+	// If non-zero, the data section starts at a multiple of value_alignment
+	// bytes into the file, and aligned values are preceded by zero padding so
+	// that they also start at a multiple of value_alignment.
+	ValueAlignment uint32 `protobuf:"varint,11,opt,name=value_alignment,json=valueAlignment" json:"value_alignment,omitempty"`
+	// Total length of padding within the value data.
+	DataPadding uint64 `protobuf:"varint,12,opt,name=data_padding,json=dataPadding" json:"data_padding,omitempty"`
+	// If set, the table is a key set, and the index only contains keys.
+	KeySet bool `protobuf:"varint,13,opt,name=key_set,json=keySet" json:"key_set,omitempty"`
 }
 
 func (m *TableHeader) Reset()                    { *m = TableHeader{} }
@@ -66,15 +133,26 @@ func (m *TableHeader) String() string            { return proto1.CompactTextStri
 func (*TableHeader) ProtoMessage()               {}
 func (*TableHeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
+func (m *TableHeader) GetEncryption() *Encryption {
+	if m != nil {
+		return m.Encryption
+	}
+	return nil
+}
+
 type IndexEntry struct {
-	// Key. Arbitrary array of up to 256 bytes.
+	// Key suffix. The full key is the first shared_prefix bytes of the previous
+	// entry's key followed by this. Keys are arbitrary arrays of up to
+	// TableHeader.max_key_length bytes.
 	Key []byte `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
 	// Offset of value, relative to the start of the value section.
 	Offset uint64 `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
-	// Length of value.
-	Length uint32 `protobuf:"varint,3,opt,name=length" json:"length,omitempty"`
+	// Length of value. For encrypted tables, this is the decrypted length.
+	Length uint64 `protobuf:"varint,3,opt,name=length" json:"length,omitempty"`
 	// Extra data associated with this entry.
 	Extra []byte `protobuf:"bytes,4,opt,name=extra,proto3" json:"extra,omitempty"`
+	// Number of leading bytes shared with the previous entry's key.
+	SharedPrefix uint32 `protobuf:"varint,5,opt,name=shared_prefix,json=sharedPrefix" json:"shared_prefix,omitempty"`
 }
 
 func (m *IndexEntry) Reset()                    { *m = IndexEntry{} }
@@ -82,30 +160,72 @@ func (m *IndexEntry) String() string            { return proto1.CompactTextStrin
 func (*IndexEntry) ProtoMessage()               {}
 func (*IndexEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
 
+// The index is encrypted as a single block. Each value is split into
+// chunk_size byte chunks, which are encrypted separately to allow random
+// access. Every encrypted block is followed by its authentication tag. Nonces
+// are 12 bytes: a 4 byte (little endian) domain, 0 for the index and 1 for
+// values, followed by an 8 byte (little endian) offset of the block within the
+// index or value data.
+type Encryption struct {
+	Cipher Encryption_Cipher `protobuf:"varint,1,opt,name=cipher,enum=proto.Encryption_Cipher" json:"cipher,omitempty"`
+	// Per-table data key, wrapped by the caller's key provider.
+	WrappedKey []byte `protobuf:"bytes,2,opt,name=wrapped_key,json=wrappedKey,proto3" json:"wrapped_key,omitempty"`
+	// Size of value chunks, before encryption.
+	ChunkSize uint32 `protobuf:"varint,3,opt,name=chunk_size,json=chunkSize" json:"chunk_size,omitempty"`
+}
+
+func (m *Encryption) Reset()                    { *m = Encryption{} }
+func (m *Encryption) String() string            { return proto1.CompactTextString(m) }
+func (*Encryption) ProtoMessage()               {}
+func (*Encryption) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
 func init() {
 	proto1.RegisterType((*TableHeader)(nil), "proto.TableHeader")
 	proto1.RegisterType((*IndexEntry)(nil), "proto.IndexEntry")
+	proto1.RegisterType((*Encryption)(nil), "proto.Encryption")
 	proto1.RegisterEnum("proto.TableHeader_Compression", TableHeader_Compression_name, TableHeader_Compression_value)
+	proto1.RegisterEnum("proto.TableHeader_Checksum", TableHeader_Checksum_name, TableHeader_Checksum_value)
+	proto1.RegisterEnum("proto.Encryption_Cipher", Encryption_Cipher_name, Encryption_Cipher_value)
 }
 
 func init() { proto1.RegisterFile("table.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 245 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x54, 0x90, 0x41, 0x4b, 0x03, 0x31,
-	0x10, 0x85, 0x8d, 0xdd, 0x56, 0x99, 0xdd, 0x4a, 0x1c, 0x44, 0x72, 0x92, 0x76, 0xbd, 0xf4, 0xb4,
-	0x07, 0xfd, 0x07, 0x4a, 0xc1, 0x62, 0xa9, 0x10, 0x3c, 0x79, 0x91, 0xad, 0x3b, 0xd5, 0xc5, 0x9a,
-	0x94, 0x24, 0x48, 0xfb, 0x6b, 0xfd, 0x2b, 0x92, 0x49, 0x8b, 0xeb, 0x69, 0xe7, 0x7d, 0xbc, 0xf7,
-	0x76, 0x26, 0x90, 0x87, 0x7a, 0xb9, 0xa6, 0x6a, 0xe3, 0x6c, 0xb0, 0xd8, 0xe7, 0x4f, 0xf9, 0x23,
-	0x20, 0x7f, 0x8e, 0xf8, 0x81, 0xea, 0x86, 0x1c, 0x2a, 0x38, 0xf9, 0x26, 0xe7, 0x5b, 0x6b, 0x94,
-	0x18, 0x89, 0xc9, 0x50, 0x1f, 0x24, 0x3e, 0xc2, 0x79, 0x6b, 0x1a, 0xda, 0xbe, 0xbe, 0xd9, 0xaf,
-	0x8d, 0x23, 0xcf, 0x9e, 0xe3, 0x91, 0x98, 0x9c, 0xdd, 0x5c, 0xa5, 0xce, 0xaa, 0x53, 0x54, 0xdd,
-	0xff, 0xb9, 0xb4, 0xe4, 0x60, 0x87, 0xe0, 0x18, 0x8a, 0x54, 0xb6, 0x26, 0xf3, 0x1e, 0x3e, 0x54,
-	0x8f, 0xff, 0x95, 0x33, 0x9b, 0x33, 0xc2, 0x6b, 0x18, 0x26, 0x0b, 0x99, 0xe0, 0x5a, 0xf2, 0x2a,
-	0x63, 0x4f, 0xca, 0x4d, 0x13, 0x2b, 0xc7, 0x90, 0x77, 0x6b, 0x4f, 0x21, 0x5b, 0x3c, 0x2d, 0xa6,
-	0xf2, 0x28, 0x4e, 0x2f, 0xf3, 0xd9, 0x9d, 0x14, 0x65, 0x03, 0x30, 0x3b, 0x44, 0x76, 0x28, 0xa1,
-	0xf7, 0x49, 0x3b, 0xbe, 0xad, 0xd0, 0x71, 0xc4, 0x4b, 0x18, 0xd8, 0xd5, 0xca, 0x53, 0xe0, 0x63,
-	0x32, 0xbd, 0x57, 0x91, 0xff, 0x5b, 0x6e, 0xaf, 0xf0, 0x02, 0xfa, 0xb4, 0x0d, 0xae, 0xe6, 0x7d,
-	0x0a, 0x9d, 0xc4, 0x72, 0xc0, 0x2f, 0x70, 0xfb, 0x1b, 0x00, 0x00, 0xff, 0xff, 0x4c, 0xb5, 0xa0,
-	0xb2, 0x64, 0x01, 0x00, 0x00,
+	// 567 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x92, 0x5d, 0x4f, 0xdb, 0x3c,
+	0x14, 0xc7, 0x09, 0xb4, 0xa1, 0x9c, 0xa4, 0x25, 0x58, 0x88, 0x27, 0xd2, 0xa3, 0x6d, 0x21, 0x6c,
+	0x22, 0x57, 0xd5, 0x56, 0xb6, 0xdd, 0x43, 0x54, 0x0d, 0x54, 0xde, 0xe4, 0x6e, 0x37, 0xbb, 0x89,
+	0x4c, 0x72, 0xa0, 0x51, 0xdb, 0x34, 0x72, 0xdc, 0xae, 0xe1, 0x13, 0x6c, 0x9f, 0x63, 0x5f, 0x74,
+	0xb2, 0x9d, 0x40, 0xd0, 0x76, 0x95, 0x9c, 0x9f, 0xff, 0x3e, 0xfe, 0x9f, 0x17, 0xb0, 0x04, 0xbb,
+	0x9b, 0x61, 0x3f, 0xe7, 0x0b, 0xb1, 0x20, 0x6d, 0xf5, 0xf1, 0x7f, 0xb6, 0xc1, 0xfa, 0x2a, 0xf1,
+	0x39, 0xb2, 0x04, 0x39, 0x71, 0x61, 0x7b, 0x85, 0xbc, 0x48, 0x17, 0x99, 0x6b, 0x78, 0x46, 0xd0,
+	0xa5, 0x75, 0x48, 0x46, 0xb0, 0x97, 0x66, 0x09, 0xae, 0xa3, 0x78, 0x31, 0xcf, 0x39, 0x16, 0x4a,
+	0xb3, 0xe9, 0x19, 0x41, 0x6f, 0xf0, 0x5a, 0xe7, 0xec, 0x37, 0x12, 0xf5, 0xc3, 0x67, 0x15, 0x75,
+	0xd4, 0xc5, 0x06, 0x21, 0x87, 0x60, 0xeb, 0x64, 0x33, 0xcc, 0x1e, 0xc4, 0xc4, 0xdd, 0xf2, 0x8c,
+	0xa0, 0x45, 0x2d, 0xc5, 0x2e, 0x15, 0x22, 0x47, 0xd0, 0xd5, 0x12, 0xcc, 0x04, 0x4f, 0xb1, 0x70,
+	0x5b, 0x4a, 0xa3, 0xef, 0x0d, 0x35, 0x23, 0x6f, 0xa1, 0x37, 0x67, 0xeb, 0x68, 0x8a, 0x65, 0x9d,
+	0xa9, 0xad, 0x5c, 0xdb, 0x73, 0xb6, 0x1e, 0x61, 0x59, 0xa5, 0x7a, 0x07, 0x3d, 0x9d, 0x8a, 0x63,
+	0x21, 0x18, 0x17, 0x85, 0x6b, 0x7a, 0x5b, 0x41, 0x8b, 0xea, 0x07, 0x68, 0x05, 0xc9, 0x47, 0x38,
+	0x78, 0x21, 0x8b, 0xd2, 0x4c, 0x20, 0x5f, 0xb1, 0x99, 0xbb, 0xad, 0x92, 0xee, 0x37, 0xe5, 0x17,
+	0xd5, 0x19, 0x39, 0x83, 0xde, 0x8a, 0xcd, 0x96, 0x18, 0xc5, 0x13, 0x8c, 0xa7, 0xc5, 0x72, 0xee,
+	0x76, 0x54, 0x53, 0xfe, 0xff, 0x57, 0x53, 0x2a, 0x09, 0xed, 0xaa, 0x2b, 0x75, 0x48, 0xde, 0x80,
+	0x95, 0x30, 0xc1, 0xea, 0x1a, 0x76, 0x54, 0xa5, 0x20, 0x51, 0x55, 0xc1, 0x07, 0x00, 0xcc, 0x62,
+	0x5e, 0xe6, 0x42, 0x76, 0x1d, 0x3c, 0x23, 0xb0, 0x06, 0x7b, 0xd5, 0x03, 0xc3, 0xa7, 0x03, 0xda,
+	0x10, 0x91, 0x63, 0xd8, 0xd5, 0xbe, 0xd8, 0x2c, 0x7d, 0xc8, 0xe6, 0x98, 0x09, 0xd7, 0x52, 0x65,
+	0x68, 0xbb, 0xa7, 0x35, 0x95, 0xb3, 0x50, 0x8f, 0xe7, 0x2c, 0x49, 0xd2, 0xec, 0xc1, 0xb5, 0xf5,
+	0x2c, 0x24, 0xbb, 0xd5, 0x88, 0xfc, 0x07, 0xdb, 0xb2, 0xc5, 0x05, 0x0a, 0xb7, 0xeb, 0x19, 0x41,
+	0x87, 0x9a, 0x53, 0x2c, 0xc7, 0x28, 0xfc, 0x43, 0xb0, 0x9a, 0x63, 0xed, 0x40, 0xeb, 0xfa, 0xe6,
+	0x7a, 0xe8, 0x6c, 0xc8, 0xbf, 0xef, 0x97, 0x17, 0x67, 0x8e, 0xe1, 0x1f, 0x43, 0xe7, 0xa9, 0xce,
+	0x5d, 0xb0, 0xae, 0x6f, 0xa2, 0xf0, 0x7c, 0x18, 0x8e, 0xc6, 0xdf, 0xae, 0x9c, 0x0d, 0x02, 0x60,
+	0x86, 0x34, 0x3c, 0x19, 0x84, 0x8e, 0xe1, 0xff, 0x32, 0x00, 0x2e, 0xea, 0xe1, 0x96, 0xc4, 0x81,
+	0xad, 0x29, 0x96, 0x6a, 0x0b, 0x6d, 0x2a, 0x7f, 0xc9, 0x01, 0x98, 0x8b, 0xfb, 0x7b, 0x69, 0x62,
+	0x53, 0x59, 0xac, 0x22, 0xc9, 0x5f, 0xac, 0x51, 0x15, 0x91, 0x7d, 0x68, 0xe3, 0x5a, 0x70, 0xa6,
+	0x36, 0xc7, 0xa6, 0x3a, 0x90, 0x7b, 0x55, 0x4c, 0x18, 0xc7, 0x24, 0xca, 0x39, 0xde, 0xa7, 0xeb,
+	0x7a, 0x63, 0x34, 0xbc, 0x55, 0xcc, 0xff, 0x6d, 0x00, 0x3c, 0xf7, 0x95, 0xbc, 0x07, 0x33, 0x4e,
+	0xf3, 0x09, 0x72, 0x65, 0xa7, 0x37, 0x70, 0xff, 0x6a, 0x7d, 0x3f, 0x54, 0xe7, 0xb4, 0xd2, 0xc9,
+	0x89, 0xfe, 0xe0, 0x2c, 0xcf, 0x31, 0x91, 0xcb, 0xa9, 0x0c, 0xdb, 0x14, 0x2a, 0x34, 0xc2, 0x92,
+	0xbc, 0x02, 0x88, 0x27, 0xcb, 0x6c, 0x1a, 0x15, 0xe9, 0x23, 0x2a, 0xe3, 0x5d, 0xba, 0xa3, 0xc8,
+	0x38, 0x7d, 0x44, 0xff, 0x08, 0x4c, 0x9d, 0xb1, 0xd1, 0xd3, 0x5d, 0xb0, 0x4e, 0x87, 0xe3, 0x68,
+	0xf0, 0xe9, 0x73, 0xf4, 0x25, 0xbc, 0x72, 0x8c, 0x3b, 0x53, 0xb9, 0x38, 0xf9, 0x33, 0x00, 0x0a,
+	0xb4, 0xb5, 0x75, 0xd9, 0x03, 0x00, 0x00,
 }
@@ -0,0 +1,81 @@
+package sstable
+
+import (
+	"sort"
+)
+
+// Returns the range of entries [i, j) with keys in [start, end). A nil end
+// means the end of the table.
+func (t *Table) entryRange(start, end []byte) (i, j int) {
+	i = t.index.search(start)
+	j = t.index.len()
+	if end != nil {
+		j = t.index.search(end)
+	}
+	if j < i {
+		j = i
+	}
+	return i, j
+}
+
+// Returns the total size of keys and stored values in entries before i.
+// Cumulative sizes are computed on first use.
+func (t *Table) sizeBefore(i int) uint64 {
+	if i == 0 {
+		return 0
+	}
+	t.sizesOnce.Do(t.computeSizes)
+	return t.sizeEnds[i-1]
+}
+
+func (t *Table) computeSizes() {
+	t.sizeEnds = make([]uint64, t.index.len())
+	var size uint64
+	for i := range t.sizeEnds {
+		size += t.storedLength(i) + uint64(t.index.keyLen(i))
+		t.sizeEnds[i] = size
+	}
+}
+
+// CountRange returns the number of keys in the range [start, end). A nil end
+// means the end of the table.
+func (t *Table) CountRange(start, end []byte) int {
+	i, j := t.entryRange(start, end)
+	return j - i
+}
+
+// ApproximateSize returns the total size of keys and values (in bytes) in the
+// range [start, end). A nil end means the end of the table. The result is
+// exact, since the whole index is held in memory.
+func (t *Table) ApproximateSize(start, end []byte) uint64 {
+	i, j := t.entryRange(start, end)
+	return t.sizeBefore(j) - t.sizeBefore(i)
+}
+
+// SplitPoints returns up to n-1 keys which split the table into n shards of
+// roughly equal size (keys and values). Shard i contains keys in the range
+// [points[i-1], points[i]). Fewer points are returned if the table has too few
+// keys.
+func (t *Table) SplitPoints(n int) [][]byte {
+	numEntries := t.index.len()
+	if n <= 1 || numEntries == 0 {
+		return nil
+	}
+
+	total := t.sizeBefore(numEntries)
+	var points [][]byte
+	prev := 0
+	for k := 1; k < n; k++ {
+		target := total * uint64(k) / uint64(n)
+		// First entry which starts at or after the target size.
+		i := sort.Search(numEntries, func(i int) bool {
+			return t.sizeBefore(i) >= target
+		})
+		if i <= prev || i >= numEntries {
+			continue
+		}
+		points = append(points, t.index.key(i))
+		prev = i
+	}
+	return points
+}
@@ -0,0 +1,59 @@
+package sstable
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRanges(t *testing.T) {
+	entries := make(map[string]testValuePair)
+	for i := 0; i < 100; i++ {
+		// 6 byte keys and 4 byte values, for 10 bytes per entry.
+		entries[fmt.Sprintf("key%03d", i)] = testValuePair{fmt.Sprintf("v%03d", i), nil}
+	}
+	table, err := buildReader(t, buildTable(t, entries))
+	if err != nil {
+		t.Fatal("Error building table", err)
+	}
+
+	if c := table.CountRange(nil, nil); c != 100 {
+		t.Error("Unexpected count", c)
+	}
+	if c := table.CountRange([]byte("key010"), []byte("key020")); c != 10 {
+		t.Error("Unexpected count", c)
+	}
+	if c := table.CountRange([]byte("key0105"), []byte("key020")); c != 9 {
+		t.Error("Unexpected count", c)
+	}
+	if c := table.CountRange([]byte("key020"), []byte("key010")); c != 0 {
+		t.Error("Unexpected count", c)
+	}
+
+	if s := table.ApproximateSize(nil, nil); s != 1000 {
+		t.Error("Unexpected size", s)
+	}
+	if s := table.ApproximateSize([]byte("key010"), []byte("key020")); s != 100 {
+		t.Error("Unexpected size", s)
+	}
+	if s := table.ApproximateSize([]byte("zzz"), nil); s != 0 {
+		t.Error("Unexpected size", s)
+	}
+
+	points := table.SplitPoints(4)
+	expected := []string{"key025", "key050", "key075"}
+	if len(points) != len(expected) {
+		t.Fatal("Unexpected split points", points)
+	}
+	for i, p := range points {
+		if string(p) != expected[i] {
+			t.Error("Unexpected split point", expected[i], string(p))
+		}
+	}
+
+	if points := table.SplitPoints(1000); len(points) != 99 {
+		t.Error("Unexpected number of split points", len(points))
+	}
+	if points := table.SplitPoints(1); points != nil {
+		t.Error("Unexpected split points", points)
+	}
+}
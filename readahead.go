@@ -0,0 +1,178 @@
+package sstable
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+const DefaultReadAheadWindow = 1024 * 1024
+
+type ReadAheadOptions struct {
+	// Number of bytes read ahead. If 0, DefaultReadAheadWindow is used.
+	Window int
+
+	// Read the next window in a background goroutine while the current one is
+	// being consumed.
+	Background bool
+}
+
+// SeekReadAhead is like Seek, but value readers returned by the iterator read
+// ahead when values are accessed sequentially, which makes full scans much
+// faster on high-latency storage. The iterator must be closed when done.
+func (t *Table) SeekReadAhead(key []byte, opts ReadAheadOptions) *Iter {
+	iter := t.Seek(key)
+	if opts.Window <= 0 {
+		opts.Window = DefaultReadAheadWindow
+	}
+	ra := &readAhead{
+		r:          t.r,
+		window:     opts.Window,
+		background: opts.Background,
+		limit:      -1,
+	}
+	if t.dataLength > 0 {
+		ra.limit = int64(t.dataOffset + t.dataLength)
+	}
+	if iter.Valid() {
+		ra.next = int64(t.dataOffset + t.index.offset(iter.i))
+	}
+	iter.ra = ra
+	return iter
+}
+
+// A range of the file read into memory.
+type readAheadBuf struct {
+	off  int64
+	data []byte
+	err  error
+	done chan struct{}
+}
+
+func (b *readAheadBuf) contains(off int64) bool {
+	return off >= b.off && off < b.off+int64(len(b.data))
+}
+
+// Wraps an io.ReaderAt, reading a window of data ahead of sequential reads.
+// Non-sequential reads are passed through to the underlying reader.
+type readAhead struct {
+	r          io.ReaderAt
+	window     int
+	background bool
+	// End of the data section, or -1 if unknown.
+	limit int64
+
+	lock sync.Mutex
+	// Offset where the next sequential read is expected.
+	next    int64
+	cur     *readAheadBuf
+	pending *readAheadBuf
+	closed  bool
+}
+
+func (r *readAhead) ReadAt(p []byte, off int64) (int, error) {
+	return r.ReadAtContext(context.Background(), p, off)
+}
+
+func (r *readAhead) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	n := 0
+	for n < len(p) {
+		if r.cur != nil && r.cur.contains(off) {
+			c := copy(p[n:], r.cur.data[off-r.cur.off:])
+			n += c
+			off += int64(c)
+			r.next = off
+			continue
+		}
+
+		sequential := off >= r.next && off < r.next+int64(r.window)
+		if r.closed || !sequential || len(p)-n >= r.window {
+			m, err := readAtContext(ctx, r.r, p[n:], off)
+			n += m
+			r.next = off + int64(m)
+			return n, err
+		}
+
+		if r.pending != nil && off >= r.pending.off {
+			b := r.pending
+			r.pending = nil
+			select {
+			case <-b.done:
+			case <-ctx.Done():
+				// Keep the read going, so it can be used later.
+				r.pending = b
+				return n, ctx.Err()
+			}
+			r.cur = b
+		}
+		if r.cur == nil || !r.cur.contains(off) {
+			r.cur = r.fill(ctx, off)
+		}
+		if !r.cur.contains(off) {
+			err := r.cur.err
+			if err == nil {
+				err = io.EOF
+			}
+			r.cur = nil
+			return n, err
+		}
+		if r.background && r.cur.err == nil {
+			r.startPending(r.cur.off + int64(len(r.cur.data)))
+		}
+	}
+	return n, nil
+}
+
+// Returns the length to read at off, or 0 if off is at the end of the data.
+func (r *readAhead) fillLength(off int64) int {
+	length := int64(r.window)
+	if r.limit >= 0 && off+length > r.limit {
+		length = r.limit - off
+	}
+	if length < 0 {
+		return 0
+	}
+	return int(length)
+}
+
+func (r *readAhead) fill(ctx context.Context, off int64) *readAheadBuf {
+	b := &readAheadBuf{off: off, data: make([]byte, r.fillLength(off))}
+	if len(b.data) == 0 {
+		b.err = io.EOF
+		return b
+	}
+	n, err := readAtContext(ctx, r.r, b.data, off)
+	b.data = b.data[:n]
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	b.err = err
+	return b
+}
+
+func (r *readAhead) startPending(off int64) {
+	if r.pending != nil || r.fillLength(off) == 0 {
+		return
+	}
+	b := &readAheadBuf{off: off, done: make(chan struct{})}
+	r.pending = b
+	go func() {
+		filled := r.fill(context.Background(), off)
+		b.data, b.err = filled.data, filled.err
+		close(b.done)
+	}()
+}
+
+func (r *readAhead) close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closed = true
+	r.cur = nil
+	if r.pending != nil {
+		<-r.pending.done
+		r.pending = nil
+	}
+}
@@ -0,0 +1,96 @@
+package sstable
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads int32
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	atomic.AddInt32(&r.reads, 1)
+	return r.r.ReadAt(p, off)
+}
+
+func TestReadAhead(t *testing.T) {
+	entries := generateEntries(600)
+	for _, background := range []bool{false, true} {
+		cr := &countingReaderAt{r: bytes.NewReader(buildTable(t, entries))}
+		table, err := Load(cr)
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		}
+
+		loadReads := cr.reads
+		iter := table.SeekReadAhead(nil, ReadAheadOptions{Window: 8192, Background: background})
+		count := 0
+		for ; iter.Valid(); iter.Next() {
+			r := iter.Reader()
+			value := make([]byte, r.Size())
+			n, err := r.ReadAt(value, 0)
+			if err != nil && !(err == io.EOF && n == len(value)) {
+				t.Fatal("Error reading value", err)
+			}
+			if string(value) != entries[string(iter.Key())].val {
+				t.Error("Unexpected value for key", string(iter.Key()))
+			}
+			count++
+		}
+		iter.Close()
+		if count != len(entries) {
+			t.Error("Unexpected count", count)
+		}
+		// ~100k of values, read in 8k windows.
+		if reads := cr.reads - loadReads; reads > 15 {
+			t.Error("Too many reads", reads, "background", background)
+		}
+	}
+}
+
+func TestReadAhead_Skip(t *testing.T) {
+	entries := generateEntries(600)
+	cr := &countingReaderAt{r: bytes.NewReader(buildTable(t, entries))}
+	table, err := Load(cr)
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+
+	iter := table.SeekReadAhead([]byte("key0500"), ReadAheadOptions{Window: 1024, Background: true})
+	defer iter.Close()
+	for i := 0; iter.Valid(); iter.Next() {
+		// Only read some values, and skip others.
+		i++
+		if i%7 != 0 {
+			continue
+		}
+		value := make([]byte, iter.ValueSize())
+		n, err := iter.Reader().ReadAt(value, 0)
+		if err != nil && !(err == io.EOF && n == len(value)) {
+			t.Fatal("Error reading value", err)
+		}
+		if string(value) != entries[string(iter.Key())].val {
+			t.Error("Unexpected value for key", string(iter.Key()))
+		}
+	}
+
+	// Random reads through the iterator's readers still work.
+	iter2 := table.SeekReadAhead(nil, ReadAheadOptions{Window: 1024})
+	defer iter2.Close()
+	for _, k := range []string{"key0590", "key0100", "key0101", "key0599"} {
+		r := table.Seek([]byte(k)).Reader()
+		r.r = iter2.ra
+		value := make([]byte, r.Size())
+		n, err := r.ReadAt(value, 0)
+		if err != nil && !(err == io.EOF && n == len(value)) {
+			t.Fatal("Error reading value", err)
+		}
+		if string(value) != entries[k].val {
+			t.Error("Unexpected value for key", k)
+		}
+	}
+}
@@ -2,20 +2,20 @@ package sstable
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"sort"
+	"runtime"
+	"sync"
 
 	"github.com/golang/protobuf/proto"
 
 	pb "github.com/akmistry/simple-sstable/proto"
 )
 
-type indexEntry pb.IndexEntry
-
 type TableStats struct {
 	// Number of keys in the table
 	NumKeys int
@@ -26,27 +26,81 @@ type TableStats struct {
 	// Total size of values (bytes)
 	ValuesSize int64
 
+	// Size of the stored value data (bytes), including padding. Less than
+	// ValuesSize if values are deduplicated, and more if they are encrypted.
+	StoredValuesSize int64
+
+	// Padding used to align values (bytes), including padding before the
+	// value data.
+	PaddingSize int64
+
 	// Size of header (bytes)
 	HeaderSize int
 
 	// Size of index (bytes)
-	IndexSize int
+	IndexSize int64
+
+	// Memory used by the loaded index (bytes)
+	IndexMemory int64
 }
 
 type Table struct {
 	r     io.ReaderAt
 	stats TableStats
 
-	dataOffset uint64
+	version      uint32
+	dataOffset   uint64
+	dataLength   uint64
+	alignment    uint64
+	dataPadding  uint64
+	maxKeyLength int
+
+	// CRC-32C checksum of each value, or nil if the table has no checksums.
+	checksums []uint32
 
-	indexEntries []indexEntry
+	// nil if the table is unencrypted.
+	cipher *tableCipher
+
+	// Set if the table is a key set.
+	keySet bool
+
+	// Cumulative size of keys and stored values. See sizeBefore.
+	sizesOnce sync.Once
+	sizeEnds  []uint64
+
+	index *index
 }
 
 var ErrNotFound = errors.New("Not found")
 
+// The index is read into memory in one piece, so its size must fit in an int.
+const maxIndexLength = uint64(^uint(0) >> 1)
+
+type LoadOptions struct {
+	// Number of goroutines used to decode the index. If 0, GOMAXPROCS is used.
+	Concurrency int
+
+	// Used to unwrap the data key of encrypted tables.
+	KeyProvider KeyProvider
+}
+
 func Load(r io.ReaderAt) (*Table, error) {
-	reader := &Table{r: r}
-	err := reader.readIndex()
+	return LoadContext(context.Background(), r, LoadOptions{})
+}
+
+func LoadWithOptions(r io.ReaderAt, opts LoadOptions) (*Table, error) {
+	return LoadContext(context.Background(), r, opts)
+}
+
+// LoadContext is like LoadWithOptions, but aborts loading when ctx is done.
+func LoadContext(ctx context.Context, r io.ReaderAt, opts LoadOptions) (*Table, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	reader := &Table{r: r, index: newIndex(0)}
+	err := reader.readIndex(ctx, concurrency, opts.KeyProvider)
 	if err != nil {
 		return nil, err
 	}
@@ -67,20 +121,21 @@ func (t *Table) Stats() TableStats {
 }
 
 func (t *Table) Close() error {
-	t.indexEntries = nil
+	t.index = newIndex(0)
+	t.checksums = nil
 	return nil
 }
 
-func (t *Table) readIndex() error {
+func (t *Table) readIndex(ctx context.Context, concurrency int, kp KeyProvider) error {
 	var headerSize [4]byte
-	_, err := t.r.ReadAt(headerSize[:], 0)
+	_, err := readAtContext(ctx, t.r, headerSize[:], 0)
 	if err != nil {
 		return err
 	}
 	hs := binary.LittleEndian.Uint32(headerSize[:])
 	t.stats.HeaderSize = int(hs)
 	headerBuf := make([]byte, hs)
-	_, err = t.r.ReadAt(headerBuf, 4)
+	_, err = readAtContext(ctx, t.r, headerBuf, 4)
 	if err != nil {
 		return err
 	}
@@ -90,97 +145,351 @@ func (t *Table) readIndex() error {
 		return err
 	}
 
-	if header.Version != 1 {
+	if header.Version != 1 && header.Version != 2 && header.Version != 3 {
 		return fmt.Errorf("Unsupported verison %d", header.Version)
+	} else if (header.Version == 3) != header.KeySet {
+		return errors.New("Key sets must be version 3")
+	} else if header.KeySet && (header.ValueChecksum != pb.TableHeader_NO_CHECKSUM || header.DataLength != 0) {
+		return errors.New("Key sets can't have values")
+	}
+	t.keySet = header.KeySet
+
+	t.maxKeyLength = MaxKeyLength
+	if header.MaxKeyLength > MaxKeyLengthLimit {
+		return fmt.Errorf("Max key length %d too large", header.MaxKeyLength)
+	} else if header.MaxKeyLength > 0 {
+		t.maxKeyLength = int(header.MaxKeyLength)
+	}
+
+	if header.ValueChecksum != pb.TableHeader_NO_CHECKSUM && header.ValueChecksum != pb.TableHeader_CRC32C {
+		return fmt.Errorf("Unsupported value checksum %v", header.ValueChecksum)
+	}
+
+	if header.Encryption != nil && header.Encryption.Cipher != pb.Encryption_NONE {
+		if header.ValueChecksum != pb.TableHeader_NO_CHECKSUM {
+			return errors.New("Checksums not supported with encryption")
+		}
+		t.cipher, err = loadCipher(kp, header.Encryption)
+		if err != nil {
+			return err
+		}
 	}
 
-	indexOffset := 4 + hs
-	t.dataOffset = uint64(indexOffset + header.IndexLength)
+	t.version = header.Version
+	indexOffset := 4 + uint64(hs)
+	t.dataOffset = indexOffset + header.IndexLength
+	t.dataLength = header.DataLength
+	if a := header.ValueAlignment; a&(a-1) != 0 {
+		return fmt.Errorf("Invalid value alignment %d", a)
+	} else if a > 0 {
+		t.alignment = uint64(a)
+		t.dataOffset = alignUp(t.dataOffset, t.alignment)
+		t.dataPadding = header.DataPadding
+		t.stats.PaddingSize = int64(t.dataOffset - indexOffset - header.IndexLength + header.DataPadding)
+	}
 	if header.IndexLength == 0 {
 		// No index, table is empty, done loading.
 		return nil
+	} else if header.IndexLength > maxIndexLength {
+		return fmt.Errorf("Index length %d too large", header.IndexLength)
+	} else if header.IndexEntries > header.IndexLength {
+		// Every entry takes at least one byte.
+		return errors.New("Invalid index encoding")
 	}
 	indexBuf := make([]byte, header.IndexLength)
-	_, err = t.r.ReadAt(indexBuf, int64(indexOffset))
+	_, err = readAtContext(ctx, t.r, indexBuf, int64(indexOffset))
 	if err != nil {
 		return err
 	}
-	t.stats.IndexSize = int(header.IndexLength)
+	t.stats.IndexSize = int64(header.IndexLength)
+	if t.cipher != nil {
+		indexBuf, err = t.cipher.open(indexBuf[:0], indexBuf, nonceDomainIndex, 0)
+		if err != nil {
+			return err
+		}
+	}
 	t.stats.NumKeys = int(header.IndexEntries)
 
-	if header.IndexEntries != 0 {
-		t.indexEntries = make([]indexEntry, int(header.IndexEntries))
+	err = t.decodeIndex(ctx, indexBuf, &header, concurrency)
+	if err != nil {
+		return err
 	}
-	offset := 0
-	for i := range t.indexEntries {
-		if offset >= len(indexBuf) {
-			return errors.New("Invalid index encoding")
-		}
+	t.stats.StoredValuesSize = int64(t.dataLength)
+	if header.Version == 1 {
+		// Version 1 tables don't record the data length, but are never
+		// deduplicated or encrypted.
+		t.stats.StoredValuesSize = t.stats.ValuesSize
+	}
+	if header.ValueChecksum == pb.TableHeader_CRC32C {
+		return t.readChecksums(ctx)
+	}
+	return nil
+}
+
+func (t *Table) readChecksums(ctx context.Context) error {
+	buf := make([]byte, 4*t.index.len())
+	n, err := readAtContext(ctx, t.r, buf, int64(t.dataOffset+t.dataLength))
+	if err == io.EOF && n == len(buf) {
+		err = nil
+	} else if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	t.checksums = make([]uint32, t.index.len())
+	for i := range t.checksums {
+		t.checksums[i] = binary.LittleEndian.Uint32(buf[4*i:])
+	}
+	t.stats.IndexMemory += int64(4 * len(t.checksums))
+	return nil
+}
+
+// Returns true if the table stores a checksum of each value.
+func (t *Table) HasChecksums() bool {
+	return t.checksums != nil
+}
+
+// Returns true if the table is a key set, which only stores keys.
+func (t *Table) IsKeySet() bool {
+	return t.keySet
+}
+
+// Returns true if the table's index and values are encrypted.
+func (t *Table) IsEncrypted() bool {
+	return t.cipher != nil
+}
+
+// Returns the number of bytes entry i's value occupies in the data section.
+func (t *Table) storedLength(i int) uint64 {
+	if t.cipher != nil {
+		return t.cipher.storedLength(t.index.length(i))
+	}
+	return t.index.length(i)
+}
 
-		entryLen, consumed := proto.DecodeVarint(indexBuf[offset:])
-		if consumed == 0 {
-			return errors.New("Invalid index encoding")
+// A contiguous range of index entries, starting at a restart point.
+type indexChunk struct {
+	buf        []byte
+	numEntries int
+
+	index      *index
+	keysSize   int
+	valuesSize int64
+	err        error
+}
+
+func (t *Table) decodeIndex(ctx context.Context, indexBuf []byte, header *pb.TableHeader, concurrency int) error {
+	chunks, err := splitIndex(indexBuf, header, concurrency)
+	if err != nil {
+		return err
+	}
+
+	if len(chunks) == 1 {
+		chunks[0].decode(ctx, t.maxKeyLength, t.keySet)
+	} else {
+		var wg sync.WaitGroup
+		for i := range chunks {
+			wg.Add(1)
+			go func(c *indexChunk) {
+				defer wg.Done()
+				c.decode(ctx, t.maxKeyLength, t.keySet)
+			}(&chunks[i])
 		}
+		wg.Wait()
+	}
 
-		entryOffset := offset + consumed
-		if entryOffset+int(entryLen) > len(indexBuf) {
-			return errors.New("Invalid index encoding")
+	parts := make([]*index, 0, len(chunks))
+	for i := range chunks {
+		c := &chunks[i]
+		if c.err != nil {
+			return c.err
 		}
-		entry := (*pb.IndexEntry)(&t.indexEntries[i])
-		err = proto.Unmarshal(indexBuf[entryOffset:entryOffset+int(entryLen)], entry)
-		if err != nil {
-			return err
+		parts = append(parts, c.index)
+		t.stats.KeysSize += c.keysSize
+		t.stats.ValuesSize += c.valuesSize
+	}
+	t.index = mergeIndexes(parts)
+
+	// Each chunk is checked for sort order, so only check the boundaries.
+	pos := 0
+	for i := 1; i < len(chunks); i++ {
+		pos += chunks[i-1].numEntries
+		if bytes.Compare(t.index.key(pos-1), t.index.key(pos)) != -1 {
+			return fmt.Errorf("Unexpected sort order, %v >= %v", t.index.key(pos-1), t.index.key(pos))
 		}
+	}
 
-		t.stats.KeysSize += len(entry.Key)
-		t.stats.ValuesSize += int64(entry.Length)
-		offset += consumed + int(entryLen)
+	t.index.compact()
+	t.stats.IndexMemory = t.index.memSize()
+	return nil
+}
+
+// Splits the index into at most n chunks, along restart points.
+func splitIndex(indexBuf []byte, header *pb.TableHeader, n int) ([]indexChunk, error) {
+	numEntries := int(header.IndexEntries)
+	restarts := header.IndexRestarts
+	interval := int(header.IndexRestartInterval)
+	if n <= 1 || len(restarts) <= 1 || interval == 0 {
+		return []indexChunk{{buf: indexBuf, numEntries: numEntries}}, nil
 	}
 
-	// Check t.indexEntries is sorted.
-	for i := 0; i < len(t.indexEntries)-1; i++ {
-		if bytes.Compare(t.indexEntries[i].Key, t.indexEntries[i+1].Key) != -1 {
-			return fmt.Errorf("Unexpected sort order, %v >= %v", t.indexEntries[i].Key, t.indexEntries[i+1].Key)
+	if len(restarts) != (numEntries+interval-1)/interval || restarts[0] != 0 {
+		return nil, errors.New("Invalid index restarts")
+	}
+	for i := 1; i < len(restarts); i++ {
+		if restarts[i] <= restarts[i-1] || restarts[i] >= uint64(len(indexBuf)) {
+			return nil, errors.New("Invalid index restarts")
 		}
 	}
 
-	return nil
+	if n > len(restarts) {
+		n = len(restarts)
+	}
+	chunks := make([]indexChunk, 0, n)
+	for i := 0; i < n; i++ {
+		startRestart := i * len(restarts) / n
+		endRestart := (i + 1) * len(restarts) / n
+		startOffset := restarts[startRestart]
+		endOffset := uint64(len(indexBuf))
+		endEntry := numEntries
+		if endRestart < len(restarts) {
+			endOffset = restarts[endRestart]
+			endEntry = endRestart * interval
+		}
+		chunks = append(chunks, indexChunk{
+			buf:        indexBuf[startOffset:endOffset],
+			numEntries: endEntry - startRestart*interval,
+		})
+	}
+	return chunks, nil
 }
 
-func (t *Table) Has(key []byte) bool {
-	return t.getEntry(key) != nil
-}
+// Number of entries decoded between context cancellation checks.
+const decodeCheckInterval = 4096
 
-func (t *Table) getEntry(key []byte) *indexEntry {
-	i := sort.Search(len(t.indexEntries), func(i int) bool {
-		cmp := bytes.Compare(key, t.indexEntries[i].Key)
-		return cmp <= 0
-	})
-	if i < len(t.indexEntries) && bytes.Compare(key, t.indexEntries[i].Key) == 0 {
-		return &t.indexEntries[i]
+func (c *indexChunk) decode(ctx context.Context, maxKeyLength int, keySet bool) {
+	if keySet {
+		c.index = newKeyIndex(c.numEntries)
+	} else {
+		c.index = newIndex(c.numEntries)
 	}
-	return nil
+	var entry pb.IndexEntry
+	var key, prev []byte
+	offset := 0
+	for i := 0; i < c.numEntries; i++ {
+		if i%decodeCheckInterval == 0 {
+			if c.err = ctx.Err(); c.err != nil {
+				return
+			}
+		}
+		if offset >= len(c.buf) {
+			c.err = errors.New("Invalid index encoding")
+			return
+		}
+
+		if keySet {
+			shared, suffix, consumed := decodeKeySetEntry(c.buf[offset:])
+			if consumed == 0 {
+				c.err = errors.New("Invalid index encoding")
+				return
+			}
+			entry = pb.IndexEntry{Key: suffix, SharedPrefix: uint32(shared)}
+			if uint64(entry.SharedPrefix) != shared {
+				c.err = errors.New("Invalid index encoding")
+				return
+			}
+			offset += consumed
+		} else {
+			entryLen, consumed := proto.DecodeVarint(c.buf[offset:])
+			if consumed == 0 {
+				c.err = errors.New("Invalid index encoding")
+				return
+			}
+
+			entryOffset := offset + consumed
+			if entryOffset+int(entryLen) > len(c.buf) {
+				c.err = errors.New("Invalid index encoding")
+				return
+			}
+			err := proto.Unmarshal(c.buf[entryOffset:entryOffset+int(entryLen)], &entry)
+			if err != nil {
+				c.err = err
+				return
+			}
+			offset += consumed + int(entryLen)
+		}
+		if int(entry.SharedPrefix) > len(prev) {
+			c.err = errors.New("Invalid index encoding")
+			return
+		}
+		key = append(append(key[:0], prev[:entry.SharedPrefix]...), entry.Key...)
+		if len(key) > maxKeyLength {
+			c.err = fmt.Errorf("Key length %d > %d", len(key), maxKeyLength)
+			return
+		}
+		// Check the index is sorted.
+		if i > 0 && bytes.Compare(prev, key) != -1 {
+			c.err = fmt.Errorf("Unexpected sort order, %v >= %v", prev, key)
+			return
+		}
+		c.index.add(key, entry.Extra, entry.Offset, entry.Length)
+		prev, key = key, prev
+
+		c.keysSize += len(key)
+		c.valuesSize += int64(entry.Length)
+	}
+	if offset != len(c.buf) {
+		c.err = errors.New("Invalid index encoding")
+	}
+}
+
+func (t *Table) Has(key []byte) bool {
+	_, ok := t.index.find(key)
+	return ok
 }
 
 type ValueReader struct {
 	t *Table
+	// Reader used for the value, which may read ahead.
+	r io.ReaderAt
 
 	extra []byte
 
 	offset int64
-	length uint32
+	length uint64
+
+	checksum    uint32
+	hasChecksum bool
 }
 
 func (r *ValueReader) Extra() []byte {
 	return r.extra
 }
 
+// Returns the offset of the value within the table.
+func (r *ValueReader) Offset() int64 {
+	return r.offset
+}
+
+// Returns the CRC-32C checksum of the value, and whether the table stores
+// checksums.
+func (r *ValueReader) Checksum() (uint32, bool) {
+	return r.checksum, r.hasChecksum
+}
+
 // Returns int64 to match various other Size() methods (i.e. FileInfo.Size())
 func (r *ValueReader) Size() int64 {
 	return int64(r.length)
 }
 
 func (r *ValueReader) ReadAt(p []byte, off int64) (int, error) {
+	return r.ReadAtContext(context.Background(), p, off)
+}
+
+// ReadAtContext is like ReadAt, but aborts the read when ctx is done.
+func (r *ValueReader) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
 	if off < 0 {
 		panic("off < 0")
 	}
@@ -194,7 +503,16 @@ func (r *ValueReader) ReadAt(p []byte, off int64) (int, error) {
 	if off+int64(readLen) > int64(r.length) {
 		readLen = int(int64(r.length) - off)
 	}
-	n, err := r.t.r.ReadAt(p[:readLen], r.offset+off)
+	var n int
+	var err error
+	if c := r.t.cipher; c != nil {
+		read := func(p []byte, off int64) (int, error) {
+			return readAtContext(ctx, r.r, p, r.offset+off)
+		}
+		n, err = c.readValue(read, p[:readLen], off, uint64(r.offset)-r.t.dataOffset, r.length)
+	} else {
+		n, err = readAtContext(ctx, r.r, p[:readLen], r.offset+off)
+	}
 	if err == io.EOF && n < readLen {
 		// Read was shorter than the expected value length, suggesting the file
 		// has been truncated. This is unexpected.
@@ -210,28 +528,41 @@ func (r *ValueReader) ReadAt(p []byte, off int64) (int, error) {
 }
 
 func (t *Table) GetReader(key []byte) (*ValueReader, error) {
-	ie := t.getEntry(key)
-	if ie == nil {
+	i, ok := t.index.find(key)
+	if !ok {
 		return nil, ErrNotFound
 	}
+	return t.valueReader(i), nil
+}
 
+func (t *Table) valueReader(i int) *ValueReader {
 	r := &ValueReader{
 		t:      t,
-		extra:  ie.Extra,
-		offset: int64(t.dataOffset + ie.Offset),
-		length: uint32(ie.Length),
+		r:      t.r,
+		extra:  t.index.extra(i),
+		offset: int64(t.dataOffset + t.index.offset(i)),
+		length: t.index.length(i),
+	}
+	if t.checksums != nil {
+		r.checksum = t.checksums[i]
+		r.hasChecksum = true
 	}
-	return r, nil
+	return r
 }
 
 func (t *Table) Get(key []byte) (value []byte, extra []byte, e error) {
+	return t.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get, but aborts reading the value when ctx is done.
+func (t *Table) GetContext(ctx context.Context, key []byte) (value []byte, extra []byte, e error) {
 	r, err := t.GetReader(key)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	value = make([]byte, int(r.Size()))
-	n, err := r.ReadAt(value, 0)
+	n, err := r.ReadAtContext(ctx, value, 0)
 	if err == io.EOF && n == len(value) {
 		// All data was read, so not an error.
 	} else if err != nil {
@@ -256,55 +587,89 @@ func (t *Table) GetPartial(key []byte, off uint, p []byte) error {
 	return err
 }
 
-func (t *Table) GetInfo(key []byte) (length uint, extra []byte, e error) {
-	ie := t.getEntry(key)
-	if ie == nil {
+func (t *Table) GetInfo(key []byte) (length uint64, extra []byte, e error) {
+	i, ok := t.index.find(key)
+	if !ok {
 		return 0, nil, ErrNotFound
 	}
-	return uint(ie.Length), ie.Extra, nil
+	return t.index.length(i), t.index.extra(i), nil
 }
 
 func (t *Table) Keys() (keys [][]byte) {
-	if len(t.indexEntries) == 0 {
+	if t.index.len() == 0 {
 		return
 	}
 
-	keys = make([][]byte, 0, len(t.indexEntries))
-	for i, _ := range t.indexEntries {
-		keys = append(keys, t.indexEntries[i].Key)
+	keys = make([][]byte, 0, t.index.len())
+	for i := 0; i < t.index.len(); i++ {
+		keys = append(keys, t.index.key(i))
 	}
 	return
 }
 
 type Iter struct {
-	t *Table
-	i int
+	t  *Table
+	i  int
+	ra *readAhead
 }
 
 func (i *Iter) Value() []byte {
-	if i.i >= len(i.t.indexEntries) {
+	if i.i >= i.t.index.len() {
 		return nil
 	}
-	return i.t.indexEntries[i.i].Key
+	return i.t.index.key(i.i)
 }
 
 func (i *Iter) Key() []byte {
-	if i.i >= len(i.t.indexEntries) {
+	if i.i >= i.t.index.len() {
 		return nil
 	}
-	return i.t.indexEntries[i.i].Key
+	return i.t.index.key(i.i)
 }
 
 func (i *Iter) ValueSize() int64 {
-	if i.i >= len(i.t.indexEntries) {
+	if i.i >= i.t.index.len() {
 		return 0
 	}
-	return int64(i.t.indexEntries[i.i].Length)
+	return int64(i.t.index.length(i.i))
+}
+
+func (i *Iter) Extra() []byte {
+	if i.i >= i.t.index.len() {
+		return nil
+	}
+	return i.t.index.extra(i.i)
+}
+
+// Returns a reader for the current entry's value, or nil if the iterator is
+// exhausted.
+func (i *Iter) Reader() *ValueReader {
+	if i.i >= i.t.index.len() {
+		return nil
+	}
+	r := i.t.valueReader(i.i)
+	if i.ra != nil {
+		r.r = i.ra
+	}
+	return r
+}
+
+// Returns true if the iterator is positioned at an entry.
+func (i *Iter) Valid() bool {
+	return i.i < i.t.index.len()
 }
 
 func (i *Iter) Next() bool {
 	i.i++
-	return i.i < len(i.t.indexEntries)
+	return i.i < i.t.index.len()
+}
+
+// Releases resources used by read-ahead. Readers returned by the iterator
+// remain usable, but no longer read ahead.
+func (i *Iter) Close() {
+	if i.ra != nil {
+		i.ra.close()
+	}
 }
 
 // Deprecated: Less bad interface TBD
@@ -312,26 +677,29 @@ func (t *Table) KeyIter() *Iter {
 	return &Iter{t: t}
 }
 
+// Returns an iterator positioned at the first key greater than or equal to the
+// given key.
+func (t *Table) Seek(key []byte) *Iter {
+	return &Iter{t: t, i: t.index.search(key)}
+}
+
 // Gets the key (and extra and value length) in the table that is less than or
 // equal to the given key. Will return nil if no such key exists.
-func (t *Table) LowerKey(key []byte) (k []byte, e []byte, n uint) {
-	i := sort.Search(len(t.indexEntries), func(i int) bool {
-		cmp := bytes.Compare(key, t.indexEntries[i].Key)
-		return cmp < 0
-	}) - 1
+func (t *Table) LowerKey(key []byte) (k []byte, e []byte, n uint64) {
+	i, ok := t.index.find(key)
+	if !ok {
+		i--
+	}
 	if i < 0 {
 		return nil, nil, 0
 	}
-	return t.indexEntries[i].Key, t.indexEntries[i].Extra, uint(t.indexEntries[i].Length)
+	return t.index.key(i), t.index.extra(i), t.index.length(i)
 }
 
-func (t *Table) UpperKey(key []byte) (k []byte, e []byte, n uint) {
-	i := sort.Search(len(t.indexEntries), func(i int) bool {
-		cmp := bytes.Compare(key, t.indexEntries[i].Key)
-		return cmp <= 0
-	})
-	if i >= len(t.indexEntries) {
+func (t *Table) UpperKey(key []byte) (k []byte, e []byte, n uint64) {
+	i := t.index.search(key)
+	if i >= t.index.len() {
 		return nil, nil, 0
 	}
-	return t.indexEntries[i].Key, t.indexEntries[i].Extra, uint(t.indexEntries[i].Length)
+	return t.index.key(i), t.index.extra(i), t.index.length(i)
 }
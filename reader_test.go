@@ -2,8 +2,10 @@ package sstable
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -150,3 +152,163 @@ func TestReader_TruncatedValue(t *testing.T) {
 		t.Errorf("Unexpected bytes read: %v", buf[:n])
 	}
 }
+
+// Keeps the first len(head) bytes written, and discards (but counts) the rest.
+type headWriter struct {
+	head []byte
+	n    int64
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if w.n < int64(len(w.head)) {
+		copy(w.head[w.n:], p)
+	}
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// Reads the stored head, followed by zeros up to size.
+type zeroTailReader struct {
+	head []byte
+	size int64
+}
+
+func (r *zeroTailReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if off+int64(n) > r.size {
+		n = int(r.size - off)
+	}
+	for i := 0; i < n; i++ {
+		pos := off + int64(i)
+		if pos < int64(len(r.head)) {
+			p[i] = r.head[pos]
+		} else {
+			p[i] = 0
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestReader_LargeValue(t *testing.T) {
+	const largeLength = 5 * 1024 * 1024 * 1024 // 5GiB
+	zeros := make([]byte, 1024*1024)
+	vf := func(key []byte, w io.Writer) (int, error) {
+		if string(key) == "small" {
+			return w.Write([]byte("tail"))
+		}
+		total := 0
+		for total < largeLength {
+			n, err := w.Write(zeros)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+		return total, nil
+	}
+
+	w := &headWriter{head: make([]byte, 4096)}
+	b := NewBuilder(w, vf)
+	b.Add([]byte("large"), largeLength, []byte{1})
+	b.Add([]byte("small"), 4, nil)
+	if err := b.Build(); err != nil {
+		t.Fatal("Error building table", err)
+	}
+
+	table, err := Load(&zeroTailReader{head: w.head, size: w.n})
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	if table.DataSize() != largeLength+4 {
+		t.Error("Incorrect data size", table.DataSize())
+	}
+
+	l, e, err := table.GetInfo([]byte("large"))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	} else if l != largeLength || !bytes.Equal(e, []byte{1}) {
+		t.Error("Unexpected info", l, e)
+	}
+
+	r, err := table.GetReader([]byte("large"))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	} else if r.Size() != largeLength {
+		t.Error("Unexpected size", r.Size())
+	}
+	buf := make([]byte, 2)
+	n, err := r.ReadAt(buf, largeLength-1)
+	if err != io.EOF || n != 1 {
+		t.Error("Unexpected read", n, err)
+	}
+
+	// The value bytes aren't retained by headWriter, only the length.
+	v, _, err := table.Get([]byte("small"))
+	if err != nil {
+		t.Error("Unexpected error", err)
+	} else if len(v) != 4 {
+		t.Error("Unexpected value length", len(v))
+	}
+}
+
+func TestReader_ParallelLoad(t *testing.T) {
+	entries := make(map[string]testValuePair)
+	for i := 0; i < 1000; i++ {
+		var extra []byte
+		if i%3 == 0 {
+			extra = []byte{byte(i)}
+		}
+		entries[fmt.Sprintf("key%05d", i)] = testValuePair{fmt.Sprint(i), extra}
+	}
+	buf := buildTableWithOptions(t, entries, BuilderOptions{IndexRestartInterval: 7})
+
+	for _, c := range []int{1, 2, 5, 1000} {
+		table, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{Concurrency: c})
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		}
+		checkTable(t, table, entries)
+		if table.NumKeys() != len(entries) {
+			t.Error("Incorrect number of keys", table.NumKeys())
+		}
+	}
+}
+
+func TestReader_Seek(t *testing.T) {
+	table, err := buildReader(t, buildTable(t, testValues))
+	if err != nil {
+		t.Fatal("Error building table", err)
+	}
+
+	iter := table.Seek([]byte("foo4"))
+	if !iter.Valid() || string(iter.Key()) != "goo" {
+		t.Error("Unexpected iterator position", iter.Key())
+	}
+	iter.Next()
+	iter.Next()
+	if string(iter.Key()) != strings.Repeat("h", 256) {
+		t.Error("Unexpected key", iter.Key())
+	}
+	iter.Next()
+	if string(iter.Key()) != "hoo" || !bytes.Equal(iter.Extra(), []byte{1, 2, 3, 4, 5}) {
+		t.Error("Unexpected entry", iter.Key(), iter.Extra())
+	}
+	r := iter.Reader()
+	buf := make([]byte, r.Size())
+	if _, err := r.ReadAt(buf, 0); err != nil && err != io.EOF {
+		t.Error("Unexpected error", err)
+	} else if string(buf) != "randomstuff" {
+		t.Error("Unexpected value", buf)
+	}
+
+	iter = table.Seek([]byte("zzzz"))
+	if iter.Valid() || iter.Key() != nil || iter.Reader() != nil {
+		t.Error("Expected exhausted iterator")
+	}
+}
@@ -0,0 +1,323 @@
+// Package remote provides io.ReaderAt implementations for tables stored on
+// remote servers.
+package remote
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	sstable "github.com/akmistry/simple-sstable"
+)
+
+const (
+	DefaultBlockSize   = 256 * 1024
+	DefaultCacheBlocks = 64
+	DefaultMaxRetries  = 3
+	DefaultRetryDelay  = 100 * time.Millisecond
+)
+
+type HTTPOptions struct {
+	// Client used to make requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Reads are made, and cached, in aligned blocks of this size. If 0,
+	// DefaultBlockSize is used.
+	BlockSize int
+
+	// Number of blocks to cache. If 0, DefaultCacheBlocks is used. Reads larger
+	// than half the cache (i.e. loading the index) bypass the cache.
+	CacheBlocks int
+
+	// Number of times a failed request is retried. If 0, DefaultMaxRetries is
+	// used. Use a negative value to disable retries.
+	MaxRetries int
+
+	// Delay before the first retry, doubling for each subsequent retry. If 0,
+	// DefaultRetryDelay is used.
+	RetryDelay time.Duration
+}
+
+// HTTPReaderAt reads a remote file using HTTP range requests. Reads of
+// adjacent blocks are coalesced into a single request, and concurrent reads of
+// the same block share one request. If the server sends an ETag, later
+// requests are made conditional on it, and reads fail with ErrChanged if the
+// file is replaced.
+type HTTPReaderAt struct {
+	url  string
+	opts HTTPOptions
+
+	lock sync.Mutex
+	// ETag of the first response, if the server sent one.
+	etag     string
+	cache    map[int64]*list.Element
+	lru      list.List
+	inflight map[int64]*blockFetch
+}
+
+type cachedBlock struct {
+	index int64
+	data  []byte
+}
+
+type blockFetch struct {
+	done chan struct{}
+	data []byte
+	err  error
+	// Number of reads waiting for the fetch.
+	waiters int
+}
+
+var (
+	_ io.ReaderAt             = (*HTTPReaderAt)(nil)
+	_ sstable.ReaderAtContext = (*HTTPReaderAt)(nil)
+
+	// Returned if the remote file changes while it is being read.
+	ErrChanged = errors.New("Remote file changed")
+
+	errRangeNotSupported = errors.New("Server does not support range requests")
+)
+
+func NewHTTPReaderAt(url string, opts HTTPOptions) *HTTPReaderAt {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultBlockSize
+	}
+	if opts.CacheBlocks <= 0 {
+		opts.CacheBlocks = DefaultCacheBlocks
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	} else if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	return &HTTPReaderAt{
+		url:      url,
+		opts:     opts,
+		cache:    make(map[int64]*list.Element),
+		inflight: make(map[int64]*blockFetch),
+	}
+}
+
+func (r *HTTPReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return r.ReadAtContext(context.Background(), p, off)
+}
+
+func (r *HTTPReaderAt) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("Negative offset")
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	if len(p) > r.opts.BlockSize*r.opts.CacheBlocks/2 {
+		data, err := r.fetch(ctx, off, int64(len(p)))
+		n := copy(p, data)
+		if err == nil && n < len(p) {
+			err = io.EOF
+		}
+		return n, err
+	}
+
+	bs := int64(r.opts.BlockSize)
+	first := off / bs
+	last := (off + int64(len(p)) - 1) / bs
+	fetches := r.getBlocks(first, last)
+
+	n := 0
+	for i, f := range fetches {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return n, ctx.Err()
+		}
+		if f.err != nil {
+			return n, f.err
+		}
+
+		blockOff := int64(0)
+		if i == 0 {
+			blockOff = off - first*bs
+		}
+		if blockOff >= int64(len(f.data)) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], f.data[blockOff:])
+		if len(f.data) < int(bs) && n < len(p) {
+			// Short block, so this is the end of the file.
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// Returns fetches for blocks [first, last], starting requests for blocks that
+// are neither cached nor being fetched. Since the requests may be shared by
+// other readers, they aren't bound to any reader's context.
+func (r *HTTPReaderAt) getBlocks(first, last int64) []*blockFetch {
+	fetches := make([]*blockFetch, 0, last-first+1)
+	var missing []*blockFetch
+	missingStart := int64(0)
+
+	r.lock.Lock()
+	for b := first; b <= last; b++ {
+		if e, ok := r.cache[b]; ok {
+			r.lru.MoveToFront(e)
+			f := &blockFetch{done: make(chan struct{}), data: e.Value.(*cachedBlock).data}
+			close(f.done)
+			fetches = append(fetches, f)
+		} else if f, ok := r.inflight[b]; ok {
+			f.waiters++
+			fetches = append(fetches, f)
+		} else {
+			f := &blockFetch{done: make(chan struct{}), waiters: 1}
+			r.inflight[b] = f
+			fetches = append(fetches, f)
+			if len(missing) > 0 && missingStart+int64(len(missing)) != b {
+				go r.fetchBlocks(missingStart, missing)
+				missing = nil
+			}
+			if len(missing) == 0 {
+				missingStart = b
+			}
+			missing = append(missing, f)
+		}
+	}
+	r.lock.Unlock()
+
+	if len(missing) > 0 {
+		go r.fetchBlocks(missingStart, missing)
+	}
+	return fetches
+}
+
+// Fetches a contiguous run of blocks in a single request.
+func (r *HTTPReaderAt) fetchBlocks(first int64, fetches []*blockFetch) {
+	bs := int64(r.opts.BlockSize)
+	data, err := r.fetch(context.Background(), first*bs, int64(len(fetches))*bs)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, f := range fetches {
+		b := first + int64(i)
+		delete(r.inflight, b)
+		if err != nil {
+			f.err = err
+			close(f.done)
+			continue
+		}
+
+		start := int64(i) * bs
+		end := start + bs
+		if start > int64(len(data)) {
+			start = int64(len(data))
+		}
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		f.data = data[start:end:end]
+		close(f.done)
+
+		r.cache[b] = r.lru.PushFront(&cachedBlock{index: b, data: f.data})
+		for r.lru.Len() > r.opts.CacheBlocks {
+			e := r.lru.Back()
+			r.lru.Remove(e)
+			delete(r.cache, e.Value.(*cachedBlock).index)
+		}
+	}
+}
+
+// Fetches [off, off+length) with retries. Returns fewer bytes if the file
+// ends before off+length.
+func (r *HTTPReaderAt) fetch(ctx context.Context, off, length int64) ([]byte, error) {
+	delay := r.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		data, retry, err := r.fetchOnce(ctx, off, length)
+		if err == nil || !retry || attempt >= r.opts.MaxRetries {
+			return data, err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+func (r *HTTPReaderAt) fetchOnce(ctx context.Context, off, length int64) (data []byte, retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+	r.lock.Lock()
+	etag := r.etag
+	r.lock.Unlock()
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		// Weak ETags can't be used with If-Range, but are still checked below.
+		req.Header.Set("If-Range", etag)
+	}
+	resp, err := r.opts.Client.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		if err := r.checkETag(resp.Header.Get("ETag")); err != nil {
+			return nil, false, err
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Offset is at or after the end of the file.
+		return nil, false, r.checkETag(resp.Header.Get("ETag"))
+	case resp.StatusCode == http.StatusOK && etag != "":
+		// The If-Range condition failed, so the whole (changed) file was sent.
+		return nil, false, ErrChanged
+	case resp.StatusCode == http.StatusOK:
+		return nil, false, errRangeNotSupported
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, true, fmt.Errorf("HTTP error: %s", resp.Status)
+	default:
+		return nil, false, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+
+	var start, end int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/", &start, &end); err != nil {
+		return nil, false, fmt.Errorf("Invalid Content-Range %q", resp.Header.Get("Content-Range"))
+	} else if start != off || end < start || end-start+1 > length {
+		return nil, false, fmt.Errorf("Unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+	}
+
+	data = make([]byte, end-start+1)
+	_, err = io.ReadFull(resp.Body, data)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	return data, false, nil
+}
+
+// Records the ETag of the first response, and checks later responses match it.
+func (r *HTTPReaderAt) checkETag(etag string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.etag == "" {
+		r.etag = etag
+	} else if etag != "" && etag != r.etag {
+		return ErrChanged
+	}
+	return nil
+}
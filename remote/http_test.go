@@ -0,0 +1,261 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sstable "github.com/akmistry/simple-sstable"
+)
+
+type testServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	data     []byte
+	etag     string
+	requests int32
+	// Number of requests to fail with a 503 before succeeding.
+	failures int32
+}
+
+func newTestServer(data []byte) *testServer {
+	s := &testServer{data: data}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		if atomic.AddInt32(&s.failures, -1) >= 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		s.lock.Lock()
+		data, etag := s.data, s.etag
+		s.lock.Unlock()
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	return s
+}
+
+// Replaces the served file.
+func (s *testServer) setData(data []byte, etag string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data = data
+	s.etag = etag
+}
+
+func testData(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestHTTPReaderAt(t *testing.T) {
+	data := testData(10000)
+	srv := newTestServer(data)
+	defer srv.Close()
+
+	r := NewHTTPReaderAt(srv.URL, HTTPOptions{BlockSize: 1000, CacheBlocks: 4})
+	for _, c := range []struct {
+		off, length int
+	}{
+		{0, 4}, {4, 100}, {999, 2}, {1500, 3000}, {9990, 10}, {0, 10000}, {123, 1},
+	} {
+		buf := make([]byte, c.length)
+		n, err := r.ReadAt(buf, int64(c.off))
+		if err != nil || n != c.length {
+			t.Errorf("ReadAt(%d, %d) = %d, %v", c.off, c.length, n, err)
+		} else if !bytes.Equal(buf, data[c.off:c.off+c.length]) {
+			t.Errorf("ReadAt(%d, %d) returned wrong data", c.off, c.length)
+		}
+	}
+}
+
+func TestHTTPReaderAt_EOF(t *testing.T) {
+	data := testData(2500)
+	srv := newTestServer(data)
+	defer srv.Close()
+
+	r := NewHTTPReaderAt(srv.URL, HTTPOptions{BlockSize: 1000})
+	buf := make([]byte, 100)
+	n, err := r.ReadAt(buf, 2450)
+	if n != 50 || err != io.EOF {
+		t.Errorf("ReadAt at end = %d, %v", n, err)
+	} else if !bytes.Equal(buf[:n], data[2450:]) {
+		t.Error("ReadAt at end returned wrong data")
+	}
+
+	for _, off := range []int64{2500, 2600, 5000} {
+		n, err = r.ReadAt(buf, off)
+		if n != 0 || err != io.EOF {
+			t.Errorf("ReadAt(%d) = %d, %v", off, n, err)
+		}
+	}
+}
+
+func TestHTTPReaderAt_Cache(t *testing.T) {
+	data := testData(10000)
+	srv := newTestServer(data)
+	defer srv.Close()
+
+	r := NewHTTPReaderAt(srv.URL, HTTPOptions{BlockSize: 1000, CacheBlocks: 4})
+	buf := make([]byte, 10)
+	for i := 0; i < 10; i++ {
+		r.ReadAt(buf, int64(i*10))
+	}
+	if srv.requests != 1 {
+		t.Errorf("requests %d != 1", srv.requests)
+	}
+
+	// Spanning three blocks, one of which is cached, requires one request for
+	// the two missing blocks.
+	buf = make([]byte, 2000)
+	r.ReadAt(buf, 500)
+	if srv.requests != 2 {
+		t.Errorf("requests %d != 2", srv.requests)
+	} else if !bytes.Equal(buf, data[500:2500]) {
+		t.Error("Wrong data")
+	}
+
+	// Evict block 0.
+	r.ReadAt(buf[:10], 3000)
+	r.ReadAt(buf[:10], 4000)
+	srv.requests = 0
+	r.ReadAt(buf[:10], 0)
+	if srv.requests != 1 {
+		t.Errorf("requests %d != 1", srv.requests)
+	}
+}
+
+func TestHTTPReaderAt_Coalesce(t *testing.T) {
+	data := testData(10000)
+	block := make(chan struct{})
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-block
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	r := NewHTTPReaderAt(srv.URL, HTTPOptions{BlockSize: 1000})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			buf := make([]byte, 10)
+			n, err := r.ReadAt(buf, int64(i*10))
+			if err != nil || n != 10 || !bytes.Equal(buf, data[i*10:i*10+10]) {
+				t.Errorf("ReadAt(%d) = %d, %v", i*10, n, err)
+			}
+		}(i)
+	}
+	// Wait for every read to be waiting on the first block's fetch.
+	for waiters := 0; waiters < 10; {
+		time.Sleep(time.Millisecond)
+		r.lock.Lock()
+		if f := r.inflight[0]; f != nil {
+			waiters = f.waiters
+		}
+		r.lock.Unlock()
+	}
+	close(block)
+	wg.Wait()
+	if requests != 1 {
+		t.Errorf("requests %d != 1", requests)
+	}
+}
+
+func TestHTTPReaderAt_Retry(t *testing.T) {
+	data := testData(1000)
+	srv := newTestServer(data)
+	defer srv.Close()
+
+	srv.failures = 2
+	r := NewHTTPReaderAt(srv.URL, HTTPOptions{RetryDelay: time.Millisecond})
+	buf := make([]byte, 10)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil || n != 10 {
+		t.Errorf("ReadAt = %d, %v", n, err)
+	} else if srv.requests != 3 {
+		t.Errorf("requests %d != 3", srv.requests)
+	}
+
+	srv.failures = 10
+	srv.requests = 0
+	r = NewHTTPReaderAt(srv.URL, HTTPOptions{MaxRetries: 2, RetryDelay: time.Millisecond})
+	_, err = r.ReadAt(buf, 0)
+	if err == nil {
+		t.Error("Expected error")
+	} else if srv.requests != 3 {
+		t.Errorf("requests %d != 3", srv.requests)
+	}
+}
+
+func TestHTTPReaderAt_Changed(t *testing.T) {
+	for _, etag := range []string{`"v1"`, `W/"v1"`} {
+		srv := newTestServer(nil)
+		srv.setData(testData(10000), etag)
+
+		r := NewHTTPReaderAt(srv.URL, HTTPOptions{BlockSize: 1000})
+		buf := make([]byte, 10)
+		if _, err := r.ReadAt(buf, 0); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		// Replace the file. Cached blocks can still be read, but new requests
+		// fail.
+		srv.setData(testData(20000), `"v2"`)
+		if _, err := r.ReadAt(buf, 0); err != nil {
+			t.Error("Unexpected error reading cached block", err)
+		}
+		if _, err := r.ReadAt(buf, 5000); err != ErrChanged {
+			t.Error("Expected ErrChanged, got", err, "etag", etag)
+		}
+		if _, err := r.ReadAt(make([]byte, 10000), 0); err != ErrChanged {
+			t.Error("Expected ErrChanged, got", err, "etag", etag)
+		}
+		srv.Close()
+	}
+}
+
+func TestHTTPReaderAt_Table(t *testing.T) {
+	values := make(map[string][]byte)
+	buf := new(bytes.Buffer)
+	b := sstable.NewBuilderWithOptions(buf, func(key []byte, w io.Writer) (int, error) {
+		return w.Write(values[string(key)])
+	}, sstable.BuilderOptions{Checksums: true})
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%04d", i)
+		values[key] = testData(i)
+		b.Add([]byte(key), uint64(i), nil)
+	}
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newTestServer(buf.Bytes())
+	defer srv.Close()
+	table, err := sstable.Load(NewHTTPReaderAt(srv.URL, HTTPOptions{BlockSize: 4096}))
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	for _, i := range []int{0, 1, 500, 999} {
+		key := fmt.Sprintf("key%04d", i)
+		v, _, err := table.Get([]byte(key))
+		if err != nil {
+			t.Errorf("Get(%s) error %v", key, err)
+		} else if !bytes.Equal(v, values[key]) {
+			t.Errorf("Get(%s) returned wrong value", key)
+		}
+	}
+}
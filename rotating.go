@@ -0,0 +1,127 @@
+package sstable
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+)
+
+type RotatingBuilderOptions struct {
+	BuilderOptions
+
+	// Start a new table rather than let a table's estimated size (see
+	// Builder.EstimatedSize) exceed this many bytes. A table with a single
+	// entry may still exceed it. If 0, table size isn't limited.
+	MaxSize uint64
+
+	// Maximum number of entries in each table. If 0, the number of entries
+	// isn't limited.
+	MaxEntries int
+}
+
+// RotatingBuilder builds a sequence of tables, starting a new table whenever
+// the current one reaches a size or entry limit. Entries must be added in
+// strictly increasing key order across all tables, so the tables have
+// disjoint key ranges.
+type RotatingBuilder struct {
+	vf      ValueWriter
+	factory SplitWriterFactory
+	opts    RotatingBuilderOptions
+
+	w      io.Writer
+	b      *Builder
+	first  []byte
+	last   []byte
+	tables []SplitTable
+
+	started bool
+	built   bool
+}
+
+var errRotatingBuilderDone = errors.New("RotatingBuilder already built")
+
+func NewRotatingBuilder(vf ValueWriter, factory SplitWriterFactory, opts RotatingBuilderOptions) *RotatingBuilder {
+	if opts.MaxEntries < 0 {
+		log.Panicf("Invalid max entries %d", opts.MaxEntries)
+	}
+	return &RotatingBuilder{vf: vf, factory: factory, opts: opts}
+}
+
+// Add adds an entry to the current table, first finishing it and starting a
+// new table if adding the entry would exceed a limit. Returns an error if
+// finishing or starting a table fails.
+func (r *RotatingBuilder) Add(key []byte, valueLength uint64, meta []byte) error {
+	if r.built {
+		return errRotatingBuilderDone
+	} else if r.started && bytes.Compare(r.last, key) != -1 {
+		log.Panicf("Key %d is before previous %d", key, r.last)
+	}
+	r.started = true
+	if r.b != nil && r.full(key, valueLength, meta) {
+		if err := r.finish(); err != nil {
+			return err
+		}
+	}
+	if r.b == nil {
+		w, err := r.factory(len(r.tables))
+		if err != nil {
+			return err
+		}
+		r.w = w
+		r.b = NewBuilderWithOptions(w, r.vf, r.opts.BuilderOptions)
+		r.first = dup(key)
+	}
+	if err := r.b.Add(key, valueLength, meta); err != nil {
+		return err
+	}
+	r.last = append(r.last[:0], key...)
+	return nil
+}
+
+// Returns true if the entry shouldn't be added to the current table.
+func (r *RotatingBuilder) full(key []byte, valueLength uint64, meta []byte) bool {
+	if r.opts.MaxEntries > 0 && r.b.NumEntries() >= r.opts.MaxEntries {
+		return true
+	}
+	if r.opts.MaxSize > 0 {
+		return r.b.estimatedSizeWith(key, valueLength, meta) > r.opts.MaxSize
+	}
+	return false
+}
+
+// Builds the current table.
+func (r *RotatingBuilder) finish() error {
+	err := r.b.Build()
+	if c, ok := r.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	r.tables = append(r.tables, SplitTable{
+		FirstKey: r.first,
+		LastKey:  dup(r.last),
+		NumKeys:  r.b.NumEntries(),
+	})
+	r.b = nil
+	r.w = nil
+	return nil
+}
+
+// Build finishes the last table, and returns all the tables built, in key
+// order. No tables are created if no entries were added.
+func (r *RotatingBuilder) Build() ([]SplitTable, error) {
+	if r.built {
+		return nil, errRotatingBuilderDone
+	}
+	r.built = true
+	if r.b != nil {
+		if err := r.finish(); err != nil {
+			return r.tables, err
+		}
+	}
+	return r.tables, nil
+}
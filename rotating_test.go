@@ -0,0 +1,116 @@
+package sstable
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestEstimatedSize(t *testing.T) {
+	entries := generateEntries(1000)
+	for _, opts := range []BuilderOptions{
+		{},
+		{Checksums: true, IndexRestartInterval: 16},
+		{ValueAlignment: 512},
+		{KeyProvider: &testKeyProvider{}},
+		{Dedup: true},
+	} {
+		buf := new(bytes.Buffer)
+		b := NewBuilderWithOptions(buf, testValueWriter(entries), opts)
+		addTestEntries(t, b, entries)
+		if b.NumEntries() != len(entries) {
+			t.Error("Unexpected num entries", b.NumEntries())
+		}
+		estimate := b.EstimatedSize()
+		if err := b.Build(); err != nil {
+			t.Fatal(err)
+		}
+
+		actual := uint64(buf.Len())
+		if estimate < actual {
+			t.Errorf("Estimate %d less than actual size %d, options %+v", estimate, actual, opts)
+		} else if !opts.Dedup && estimate-actual > 1024 {
+			t.Errorf("Estimate %d much larger than actual size %d, options %+v", estimate, actual, opts)
+		}
+	}
+}
+
+func TestRotatingBuilder(t *testing.T) {
+	entries := generateEntries(1000)
+	vf := testValueWriter(entries)
+
+	for _, opts := range []RotatingBuilderOptions{
+		{MaxEntries: 300},
+		{MaxSize: 8192},
+		{MaxSize: 8192, MaxEntries: 50, BuilderOptions: BuilderOptions{Checksums: true}},
+		{MaxSize: 8192, BuilderOptions: BuilderOptions{KeyProvider: &testKeyProvider{}, EncryptionChunkSize: 16}},
+		{MaxSize: 8192, BuilderOptions: BuilderOptions{ValueAlignment: 512, Checksums: true}},
+	} {
+		var bufs []*bytes.Buffer
+		r := NewRotatingBuilder(vf, func(i int) (io.Writer, error) {
+			bufs = append(bufs, new(bytes.Buffer))
+			return bufs[i], nil
+		}, opts)
+		for _, e := range sourceEntries(entries) {
+			if err := r.Add(e.Key, uint64(len(e.Value)), e.Extra); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tables, err := r.Build()
+		if err != nil {
+			t.Fatal(err)
+		} else if len(tables) != len(bufs) {
+			t.Fatal("Unexpected number of tables", len(tables), len(bufs))
+		}
+
+		total := 0
+		for i, st := range tables {
+			total += st.NumKeys
+			if opts.MaxEntries > 0 && st.NumKeys > opts.MaxEntries {
+				t.Error("Too many keys", st.NumKeys)
+			}
+			if opts.MaxSize > 0 && uint64(bufs[i].Len()) > opts.MaxSize {
+				t.Error("Table too large", bufs[i].Len())
+			}
+			if i > 0 && bytes.Compare(tables[i-1].LastKey, st.FirstKey) >= 0 {
+				t.Error("Overlapping tables", tables[i-1].LastKey, st.FirstKey)
+			}
+
+			table, err := LoadWithOptions(bytes.NewReader(bufs[i].Bytes()), LoadOptions{KeyProvider: opts.KeyProvider})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if table.NumKeys() != st.NumKeys {
+				t.Error("Unexpected num keys", table.NumKeys(), st.NumKeys)
+			}
+			first, _, _ := table.UpperKey(nil)
+			last, _, _ := table.LowerKey([]byte("zzz"))
+			if !bytes.Equal(first, st.FirstKey) || !bytes.Equal(last, st.LastKey) {
+				t.Error("Unexpected key range", first, last, st)
+			}
+			for iter := table.Seek(nil); iter.Valid(); iter.Next() {
+				v, _, err := table.Get(iter.Key())
+				if err != nil || string(v) != entries[string(iter.Key())].val {
+					t.Error("Unexpected value", iter.Key(), v, err)
+				}
+			}
+		}
+		if total != len(entries) {
+			t.Error("Unexpected total keys", total)
+		}
+		if _, err := r.Build(); err == nil {
+			t.Error("Expected error building twice")
+		}
+	}
+}
+
+func TestRotatingBuilder_Empty(t *testing.T) {
+	r := NewRotatingBuilder(nil, func(i int) (io.Writer, error) {
+		t.Error("Unexpected table")
+		return io.Discard, nil
+	}, RotatingBuilderOptions{MaxEntries: 10})
+	tables, err := r.Build()
+	if err != nil || len(tables) != 0 {
+		t.Error("Unexpected result", tables, err)
+	}
+}
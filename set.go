@@ -0,0 +1,180 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Decodes a key set index entry. Returns a consumed length of 0 if the entry
+// is invalid.
+func decodeKeySetEntry(buf []byte) (shared uint64, suffix []byte, consumed int) {
+	shared, n := proto.DecodeVarint(buf)
+	if n == 0 {
+		return 0, nil, 0
+	}
+	suffixLen, m := proto.DecodeVarint(buf[n:])
+	if m == 0 || suffixLen > uint64(len(buf)-n-m) {
+		return 0, nil, 0
+	}
+	start := n + m
+	return shared, buf[start : start+int(suffixLen)], start + int(suffixLen)
+}
+
+type SetBuilderOptions struct {
+	// Maximum key length, up to MaxKeyLengthLimit. If 0, MaxKeyLength is used.
+	MaxKeyLength int
+
+	// Number of index entries between restart points. If 0,
+	// DefaultIndexRestartInterval is used.
+	IndexRestartInterval int
+
+	// If set, the keys are encrypted. See BuilderOptions.KeyProvider.
+	KeyProvider KeyProvider
+}
+
+// SetBuilder builds a key set, a table which only stores keys. Key sets have
+// no data section, and a smaller index than a table with empty values. They
+// are loaded with Load, like any other table, and values read from them are
+// empty.
+type SetBuilder struct {
+	b *Builder
+}
+
+func NewSetBuilder(w io.Writer) *SetBuilder {
+	return NewSetBuilderWithOptions(w, SetBuilderOptions{})
+}
+
+func NewSetBuilderWithOptions(w io.Writer, opts SetBuilderOptions) *SetBuilder {
+	b := NewBuilderWithOptions(w, nil, BuilderOptions{
+		MaxKeyLength:         opts.MaxKeyLength,
+		IndexRestartInterval: opts.IndexRestartInterval,
+		KeyProvider:          opts.KeyProvider,
+	})
+	b.keySet = true
+	return &SetBuilder{b: b}
+}
+
+// Add adds a key. Keys must be added in strictly increasing order. Returns an
+// error if the key is longer than the maximum key length, or ErrBuilderDone if
+// the set has already been built or aborted.
+func (s *SetBuilder) Add(key []byte) error {
+	if len(key) > s.b.maxKeyLength {
+		return fmt.Errorf("Key length %d > %d", len(key), s.b.maxKeyLength)
+	}
+	return s.b.Add(key, 0, nil)
+}
+
+// Returns the number of keys added.
+func (s *SetBuilder) NumEntries() int {
+	return s.b.NumEntries()
+}
+
+// EstimatedSize returns the approximate size of the set, if it were built with
+// the keys added so far.
+func (s *SetBuilder) EstimatedSize() uint64 {
+	return s.b.EstimatedSize()
+}
+
+func (s *SetBuilder) Build() error {
+	return s.b.Build()
+}
+
+// See Builder.Abort.
+func (s *SetBuilder) Abort() {
+	s.b.Abort()
+}
+
+// See Builder.Reset.
+func (s *SetBuilder) Reset(w io.Writer) {
+	s.b.Reset(w)
+}
+
+// Union adds the keys which are in any of the tables to b. The tables can be
+// any tables, not only key sets. Build must be called to write the set.
+// Returns an error if a key is too long for b.
+func Union(b *SetBuilder, tables ...*Table) error {
+	pos := make([]int, len(tables))
+	for {
+		var min []byte
+		found := false
+		for i, t := range tables {
+			if pos[i] >= t.index.len() {
+				continue
+			}
+			if k := t.index.key(pos[i]); !found || bytes.Compare(k, min) < 0 {
+				min = k
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+		if err := b.Add(min); err != nil {
+			return err
+		}
+		for i, t := range tables {
+			if pos[i] < t.index.len() && bytes.Equal(t.index.key(pos[i]), min) {
+				pos[i]++
+			}
+		}
+	}
+}
+
+// Intersect adds the keys which are in all of the tables to b. Build must be
+// called to write the set. Returns an error if a key is too long for b.
+func Intersect(b *SetBuilder, tables ...*Table) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	// Iterate over the smallest table, and look up its keys in the others.
+	smallest := 0
+	for i, t := range tables {
+		if t.index.len() < tables[smallest].index.len() {
+			smallest = i
+		}
+	}
+	t := tables[smallest]
+	for i := 0; i < t.index.len(); i++ {
+		key := t.index.key(i)
+		inAll := true
+		for _, other := range tables {
+			if !other.Has(key) {
+				inAll = false
+				break
+			}
+		}
+		if !inAll {
+			continue
+		}
+		if err := b.Add(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Difference adds the keys which are in t, but not in any of the other tables,
+// to b. Build must be called to write the set. Returns an error if a key is too
+// long for b.
+func Difference(b *SetBuilder, t *Table, others ...*Table) error {
+	for i := 0; i < t.index.len(); i++ {
+		key := t.index.key(i)
+		inOther := false
+		for _, other := range others {
+			if other.Has(key) {
+				inOther = true
+				break
+			}
+		}
+		if inOther {
+			continue
+		}
+		if err := b.Add(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
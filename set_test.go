@@ -0,0 +1,182 @@
+package sstable
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func buildSet(t *testing.T, keys []string, opts SetBuilderOptions) []byte {
+	buf := new(bytes.Buffer)
+	b := NewSetBuilderWithOptions(buf, opts)
+	for _, k := range keys {
+		if err := b.Add([]byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func loadSet(t *testing.T, keys []string, opts SetBuilderOptions) *Table {
+	table, err := LoadWithOptions(bytes.NewReader(buildSet(t, keys, opts)), LoadOptions{KeyProvider: opts.KeyProvider})
+	if err != nil {
+		t.Fatal("Error loading set", err)
+	}
+	return table
+}
+
+func setKeys(n, step int) []string {
+	var keys []string
+	for i := 0; i < n; i += step {
+		keys = append(keys, fmt.Sprintf("key%04d", i))
+	}
+	return keys
+}
+
+func tableKeys(table *Table) []string {
+	var keys []string
+	for iter := table.Seek(nil); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	return keys
+}
+
+func TestSet(t *testing.T) {
+	keys := setKeys(1000, 1)
+	entries := make(map[string]testValuePair)
+	for _, k := range keys {
+		entries[k] = testValuePair{"", nil}
+	}
+	for _, opts := range []SetBuilderOptions{
+		{},
+		{IndexRestartInterval: 16},
+		{KeyProvider: &testKeyProvider{mask: 7}},
+	} {
+		buf := buildSet(t, keys, opts)
+		table, err := LoadWithOptions(bytes.NewReader(buf), LoadOptions{KeyProvider: opts.KeyProvider, Concurrency: 4})
+		if err != nil {
+			t.Fatal("Error loading set", err)
+		}
+		if !table.IsKeySet() {
+			t.Error("Table not a key set")
+		}
+		checkTable(t, table, entries)
+		if got := tableKeys(table); len(got) != len(keys) {
+			t.Error("Unexpected keys", len(got))
+		}
+		if table.Has([]byte("key")) || table.Has([]byte("key1000")) {
+			t.Error("Unexpected key")
+		}
+		if k, _, _ := table.LowerKey([]byte("key0500x")); string(k) != "key0500" {
+			t.Error("Unexpected lower key", string(k))
+		}
+		if k, _, _ := table.UpperKey([]byte("key0500x")); string(k) != "key0501" {
+			t.Error("Unexpected upper key", string(k))
+		}
+
+		report, err := table.Verify(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		} else if !report.OK() {
+			t.Error("Unexpected problems", report.Problems)
+		}
+
+		if opts.KeyProvider == nil {
+			full := buildTableWithOptions(t, entries, BuilderOptions{IndexRestartInterval: opts.IndexRestartInterval})
+			if len(buf) >= len(full) {
+				t.Error("Expected set to be smaller than table", len(buf), len(full))
+			}
+		}
+	}
+}
+
+func TestSet_Empty(t *testing.T) {
+	table := loadSet(t, nil, SetBuilderOptions{})
+	if table.NumKeys() != 0 || !table.IsKeySet() {
+		t.Error("Unexpected set", table.NumKeys(), table.IsKeySet())
+	}
+}
+
+func TestSet_Corrupt(t *testing.T) {
+	buf := buildSet(t, setKeys(10, 1), SetBuilderOptions{})
+	// Truncate the last key.
+	buf = buf[:len(buf)-1]
+	if _, err := Load(bytes.NewReader(buf)); err == nil {
+		t.Error("Expected error loading corrupt set")
+	}
+}
+
+func checkSetOp(t *testing.T, op func(b *SetBuilder) error, expected []string) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	b := NewSetBuilder(buf)
+	if err := op(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	table, err := buildReader(t, buf.Bytes())
+	if err != nil {
+		t.Fatal("Error loading set", err)
+	}
+	got := tableKeys(table)
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected keys %v, expected %v", got, expected)
+	}
+}
+
+func TestSetOps(t *testing.T) {
+	// Multiples of 2, 3 and 5 below 30.
+	twos := loadSet(t, setKeys(30, 2), SetBuilderOptions{})
+	threes := loadSet(t, setKeys(30, 3), SetBuilderOptions{})
+	// Set operations work with any table.
+	fives, err := buildReader(t, buildTable(t, map[string]testValuePair{
+		"key0000": {"a", nil}, "key0005": {"b", nil}, "key0010": {"c", nil},
+		"key0015": {"d", nil}, "key0020": {"e", nil}, "key0025": {"f", nil},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkSetOp(t, func(b *SetBuilder) error { return Union(b, twos, threes, fives) }, []string{
+		"key0000", "key0002", "key0003", "key0004", "key0005", "key0006", "key0008",
+		"key0009", "key0010", "key0012", "key0014", "key0015", "key0016", "key0018",
+		"key0020", "key0021", "key0022", "key0024", "key0025", "key0026", "key0027",
+		"key0028",
+	})
+	checkSetOp(t, func(b *SetBuilder) error { return Intersect(b, twos, threes) }, []string{
+		"key0000", "key0006", "key0012", "key0018", "key0024",
+	})
+	checkSetOp(t, func(b *SetBuilder) error { return Intersect(b, twos, threes, fives) }, []string{
+		"key0000",
+	})
+	checkSetOp(t, func(b *SetBuilder) error { return Difference(b, fives, twos, threes) }, []string{
+		"key0005", "key0025",
+	})
+	checkSetOp(t, func(b *SetBuilder) error { return Union(b) }, nil)
+	empty := loadSet(t, []string{"", "key0001"}, SetBuilderOptions{})
+	checkSetOp(t, func(b *SetBuilder) error { return Union(b, empty, threes) }, []string{
+		"", "key0000", "key0001", "key0003", "key0006", "key0009", "key0012",
+		"key0015", "key0018", "key0021", "key0024", "key0027",
+	})
+	checkSetOp(t, func(b *SetBuilder) error { return Intersect(b) }, nil)
+}
+
+func TestSetOps_KeyTooLong(t *testing.T) {
+	long := loadSet(t, []string{strings.Repeat("k", 1000)}, SetBuilderOptions{MaxKeyLength: 1024})
+	b := NewSetBuilder(io.Discard)
+	if err := Union(b, long); err == nil {
+		t.Error("Expected error adding long key")
+	}
+	b = NewSetBuilderWithOptions(io.Discard, SetBuilderOptions{MaxKeyLength: 1024})
+	if err := Union(b, long); err != nil {
+		t.Error("Unexpected error", err)
+	}
+}
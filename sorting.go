@@ -0,0 +1,610 @@
+package sstable
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// DuplicatePolicy determines how SortingBuilder handles keys which are added
+// more than once.
+type DuplicatePolicy int
+
+const (
+	// Build fails with ErrDuplicateKey.
+	DuplicateError DuplicatePolicy = iota
+	// The most recently added entry is kept.
+	DuplicateKeepLast
+	// The first added entry is kept.
+	DuplicateKeepFirst
+)
+
+const DefaultSortMemory = 64 * 1024 * 1024
+
+var ErrDuplicateKey = errors.New("Duplicate key")
+
+type SortingBuilderOptions struct {
+	BuilderOptions
+
+	// Memory used to buffer entries before they are sorted and spilled to a
+	// temporary file (bytes). If 0, DefaultSortMemory is used.
+	MemoryLimit int
+
+	// Directory for temporary files. If empty, the default directory for
+	// temporary files is used (see os.TempDir).
+	TempDir string
+
+	Duplicates DuplicatePolicy
+}
+
+// SortingBuilder builds a table from entries added in any order. Entries are
+// buffered in memory, and spilled to temporary files in sorted runs when the
+// memory limit is exceeded. Build merges the runs directly into the table,
+// reading them once for the index and again for the values.
+type SortingBuilder struct {
+	w            io.Writer
+	opts         SortingBuilderOptions
+	maxKeyLength int
+	sorter       *externalSorter
+
+	// Set if the builder options are invalid, and returned by Add and Build.
+	err error
+	// Set once built or aborted.
+	done bool
+}
+
+func NewSortingBuilder(w io.Writer, opts SortingBuilderOptions) *SortingBuilder {
+	if opts.MemoryLimit < 0 {
+		log.Panicf("Invalid memory limit %d", opts.MemoryLimit)
+	} else if opts.MemoryLimit == 0 {
+		opts.MemoryLimit = DefaultSortMemory
+	}
+	if opts.Duplicates < DuplicateError || opts.Duplicates > DuplicateKeepFirst {
+		log.Panicf("Invalid duplicate policy %d", opts.Duplicates)
+	}
+	b := &SortingBuilder{
+		w:            w,
+		opts:         opts,
+		maxKeyLength: MaxKeyLength,
+		sorter:       newExternalSorter(opts.MemoryLimit, opts.TempDir),
+		err:          opts.BuilderOptions.validate(),
+	}
+	if b.err == nil && opts.MaxKeyLength > 0 {
+		b.maxKeyLength = opts.MaxKeyLength
+	}
+	return b
+}
+
+// Add adds an entry. The key, value and extra data are copied. Returns an
+// error if the builder options are invalid, the key is too long, or spilling
+// to a temporary file fails, or ErrBuilderDone if the table has already been
+// built or aborted.
+func (b *SortingBuilder) Add(key, value, extra []byte) error {
+	if b.done {
+		return ErrBuilderDone
+	} else if b.err != nil {
+		return b.err
+	} else if len(key) > b.maxKeyLength {
+		return fmt.Errorf("Key %q length %d > %d", key, len(key), b.maxKeyLength)
+	} else if uint64(len(value)) > MaxValueLength {
+		return fmt.Errorf("Value length %d > 1TiB", len(value))
+	}
+	return b.sorter.add(record{key: dup(key), value: dup(value), extra: dup(extra), valueLen: len(value)})
+}
+
+// Build sorts the added entries and writes the table. Temporary files are
+// removed, and the builder can't be used afterwards. Returns ErrBuilderDone if
+// the table has already been built or aborted.
+func (b *SortingBuilder) Build() error {
+	if b.done {
+		return ErrBuilderDone
+	}
+	b.done = true
+	defer b.sorter.close()
+	if b.err != nil {
+		return b.err
+	} else if err := b.sorter.finish(); err != nil {
+		return err
+	}
+
+	values := &valueCursor{open: func() (recordIter, io.Closer, error) {
+		return b.openUnique(false)
+	}}
+	defer values.close()
+	builder := NewBuilderWithOptions(b.w, values.write, b.opts.BuilderOptions)
+	if err := b.addKeys(builder); err != nil {
+		return err
+	}
+	return builder.Build()
+}
+
+// Adds the sorted keys to builder, skipping over the values.
+func (b *SortingBuilder) addKeys(builder *Builder) error {
+	keys, closer, err := b.openUnique(true)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	for {
+		r, err := keys.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := builder.Add(r.key, uint64(r.valueLen), r.extra); err != nil {
+			return err
+		}
+	}
+}
+
+// Opens the sorted records, with duplicates resolved. If skipValues is set,
+// values in temporary files aren't read.
+func (b *SortingBuilder) openUnique(skipValues bool) (recordIter, io.Closer, error) {
+	iter, closer, err := b.sorter.open(skipValues)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &uniqueIter{iter: iter, policy: b.opts.Duplicates}, closer, nil
+}
+
+// Abort discards the added entries and removes temporary files, without
+// building a table.
+func (b *SortingBuilder) Abort() {
+	b.done = true
+	b.sorter.close()
+}
+
+// Returns values from sorted records, as a ValueWriter. Usually, values are
+// requested in key order, but the cursor restarts from the beginning if an
+// earlier key is requested (i.e. when deduplicating values).
+type valueCursor struct {
+	open func() (recordIter, io.Closer, error)
+
+	iter   recordIter
+	closer io.Closer
+	cur    record
+	valid  bool
+}
+
+func (c *valueCursor) write(key []byte, w io.Writer) (int, error) {
+	if c.valid && bytes.Compare(c.cur.key, key) > 0 {
+		c.close()
+	}
+	for !c.valid || bytes.Compare(c.cur.key, key) < 0 {
+		if c.iter == nil {
+			iter, closer, err := c.open()
+			if err != nil {
+				return 0, err
+			}
+			c.iter = iter
+			c.closer = closer
+		}
+		r, err := c.iter.next()
+		if err == io.EOF {
+			return 0, fmt.Errorf("No value for key %q", key)
+		} else if err != nil {
+			return 0, err
+		}
+		c.cur = r
+		c.valid = true
+	}
+	if !bytes.Equal(c.cur.key, key) {
+		return 0, fmt.Errorf("No value for key %q", key)
+	}
+	return w.Write(c.cur.value)
+}
+
+func (c *valueCursor) close() {
+	if c.closer != nil {
+		c.closer.Close()
+	}
+	c.iter = nil
+	c.closer = nil
+	c.valid = false
+}
+
+// Resolves records with duplicate keys from a sorted iterator, according to
+// the policy.
+type uniqueIter struct {
+	iter    recordIter
+	policy  DuplicatePolicy
+	pending *record
+}
+
+func (u *uniqueIter) next() (record, error) {
+	var r record
+	if u.pending != nil {
+		r = *u.pending
+		u.pending = nil
+	} else {
+		var err error
+		if r, err = u.iter.next(); err != nil {
+			return r, err
+		}
+	}
+
+	for {
+		n, err := u.iter.next()
+		if err == io.EOF {
+			return r, nil
+		} else if err != nil {
+			return record{}, err
+		}
+		if !bytes.Equal(n.key, r.key) {
+			u.pending = &n
+			return r, nil
+		}
+		switch u.policy {
+		case DuplicateError:
+			return record{}, fmt.Errorf("%w %q", ErrDuplicateKey, r.key)
+		case DuplicateKeepLast:
+			r = n
+		}
+	}
+}
+
+type record struct {
+	key   []byte
+	value []byte
+	extra []byte
+	// Length of the value, which may have been skipped (leaving value nil).
+	valueLen int
+}
+
+// Approximate memory overhead of a buffered record, beyond its data.
+const recordOverhead = 80
+
+func (r *record) size() int {
+	return len(r.key) + len(r.value) + len(r.extra) + recordOverhead
+}
+
+type recordIter interface {
+	// Returns the next record, or io.EOF when done.
+	next() (record, error)
+}
+
+// Maximum number of runs merged at once, which limits the number of open
+// files.
+const defaultMergeFanIn = 64
+
+// Sorts records by key, spilling sorted runs to temporary files when the
+// buffered records exceed memLimit bytes.
+type externalSorter struct {
+	memLimit int
+	tmpDir   string
+	fanIn    int
+
+	buf     []record
+	bufSize int
+	runs    []string
+}
+
+func newExternalSorter(memLimit int, tmpDir string) *externalSorter {
+	return &externalSorter{memLimit: memLimit, tmpDir: tmpDir, fanIn: defaultMergeFanIn}
+}
+
+func (s *externalSorter) add(r record) error {
+	s.buf = append(s.buf, r)
+	s.bufSize += r.size()
+	if s.bufSize > s.memLimit {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *externalSorter) sortBuf() {
+	sort.SliceStable(s.buf, func(i, j int) bool {
+		return bytes.Compare(s.buf[i].key, s.buf[j].key) < 0
+	})
+}
+
+func (s *externalSorter) spill() error {
+	s.sortBuf()
+	path, err := writeRun(s.tmpDir, &sliceIter{recs: s.buf})
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, path)
+	s.buf = nil
+	s.bufSize = 0
+	return nil
+}
+
+// Finishes adding records. Buffered records stay in memory, and runs are
+// merged until they, along with the buffer, can be merged at once. After
+// this, open may be called any number of times to iterate over the sorted
+// records.
+func (s *externalSorter) finish() error {
+	s.sortBuf()
+	for len(s.runs)+1 > s.fanIn {
+		var merged []string
+		for start := 0; start < len(s.runs); start += s.fanIn {
+			end := start + s.fanIn
+			if end > len(s.runs) {
+				end = len(s.runs)
+			}
+			path, err := s.mergeRuns(s.runs[start:end])
+			if err != nil {
+				s.runs = append(s.runs[start:], merged...)
+				return err
+			}
+			merged = append(merged, path)
+		}
+		s.runs = merged
+	}
+	return nil
+}
+
+// Merges runs into a new run, removing them. Runs are merged in order, so
+// records with equal keys stay in insertion order.
+func (s *externalSorter) mergeRuns(runs []string) (string, error) {
+	if len(runs) == 1 {
+		return runs[0], nil
+	}
+	iter, closer, err := openRuns(runs, false, nil)
+	if err != nil {
+		return "", err
+	}
+	path, err := writeRun(s.tmpDir, iter)
+	closer.Close()
+	if err != nil {
+		return "", err
+	}
+	for _, run := range runs {
+		os.Remove(run)
+	}
+	return path, nil
+}
+
+// Opens the sorted records. Buffered records follow the runs, since they were
+// added last.
+func (s *externalSorter) open(skipValues bool) (recordIter, io.Closer, error) {
+	if len(s.runs) == 0 {
+		return &sliceIter{recs: s.buf}, nopCloser{}, nil
+	}
+	return openRuns(s.runs, skipValues, &sliceIter{recs: s.buf})
+}
+
+// Removes all temporary files.
+func (s *externalSorter) close() {
+	for _, path := range s.runs {
+		os.Remove(path)
+	}
+	s.runs = nil
+}
+
+// Opens and merges runs, followed by extra if it isn't nil.
+func openRuns(runs []string, skipValues bool, extra recordIter) (recordIter, io.Closer, error) {
+	var files fileCloser
+	var iters []recordIter
+	for _, path := range runs {
+		f, err := os.Open(path)
+		if err != nil {
+			files.Close()
+			return nil, nil, err
+		}
+		files = append(files, f)
+		iters = append(iters, &runIter{r: bufio.NewReader(f), skipValues: skipValues})
+	}
+	if extra != nil {
+		iters = append(iters, extra)
+	}
+	mi, err := newMergeIter(iters)
+	if err != nil {
+		files.Close()
+		return nil, nil, err
+	}
+	return mi, files, nil
+}
+
+type fileCloser []*os.File
+
+func (files fileCloser) Close() error {
+	for _, f := range files {
+		f.Close()
+	}
+	return nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+type sliceIter struct {
+	recs []record
+	i    int
+}
+
+func (i *sliceIter) next() (record, error) {
+	if i.i >= len(i.recs) {
+		return record{}, io.EOF
+	}
+	i.i++
+	return i.recs[i.i-1], nil
+}
+
+// Writes records to a new temporary file, each field as a varint length
+// followed by the data.
+type runWriter struct {
+	f      *os.File
+	w      *bufio.Writer
+	lenBuf [binary.MaxVarintLen64]byte
+}
+
+func newRunWriter(dir string) (*runWriter, error) {
+	f, err := os.CreateTemp(dir, "sstable-sort-")
+	if err != nil {
+		return nil, err
+	}
+	return &runWriter{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (w *runWriter) write(r record) error {
+	for _, field := range [][]byte{r.key, r.value, r.extra} {
+		n := binary.PutUvarint(w.lenBuf[:], uint64(len(field)))
+		w.w.Write(w.lenBuf[:n])
+		if _, err := w.w.Write(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finishes writing, and returns the file's path. The file is removed if
+// writing failed.
+func (w *runWriter) close() (string, error) {
+	err := w.w.Flush()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+		return "", err
+	}
+	return w.f.Name(), nil
+}
+
+// Removes the file, after a failure.
+func (w *runWriter) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+func writeRun(dir string, iter recordIter) (string, error) {
+	w, err := newRunWriter(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		r, err := iter.next()
+		if err == io.EOF {
+			break
+		} else if err == nil {
+			err = w.write(r)
+		}
+		if err != nil {
+			w.abort()
+			return "", err
+		}
+	}
+	return w.close()
+}
+
+var errCorruptRun = errors.New("Corrupt sort run")
+
+type runIter struct {
+	r *bufio.Reader
+	// If set, values are skipped rather than read.
+	skipValues bool
+}
+
+func (i *runIter) readField() ([]byte, error) {
+	l, err := binary.ReadUvarint(i.r)
+	if err != nil {
+		return nil, err
+	}
+	field := make([]byte, int(l))
+	_, err = io.ReadFull(i.r, field)
+	return field, err
+}
+
+func (i *runIter) skipField() (int, error) {
+	l, err := binary.ReadUvarint(i.r)
+	if err != nil {
+		return 0, err
+	}
+	n, err := i.r.Discard(int(l))
+	if err == nil && n != int(l) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (i *runIter) next() (r record, err error) {
+	r.key, err = i.readField()
+	if err != nil {
+		// io.EOF here is the clean end of the run.
+		return r, err
+	}
+	if i.skipValues {
+		r.valueLen, err = i.skipField()
+	} else {
+		r.value, err = i.readField()
+		r.valueLen = len(r.value)
+	}
+	if err != nil {
+		return r, errCorruptRun
+	}
+	if r.extra, err = i.readField(); err != nil {
+		return r, errCorruptRun
+	}
+	return r, nil
+}
+
+type mergeEntry struct {
+	r    record
+	iter int
+}
+
+// Merges sorted iterators. Records with equal keys are returned in iterator
+// order, which preserves insertion order since runs are written in order.
+type mergeIter struct {
+	iters []recordIter
+	h     mergeHeap
+}
+
+func newMergeIter(iters []recordIter) (*mergeIter, error) {
+	m := &mergeIter{iters: iters}
+	for i := range iters {
+		if err := m.push(i); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *mergeIter) push(i int) error {
+	r, err := m.iters[i].next()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	heap.Push(&m.h, mergeEntry{r, i})
+	return nil
+}
+
+func (m *mergeIter) next() (record, error) {
+	if len(m.h) == 0 {
+		return record{}, io.EOF
+	}
+	e := heap.Pop(&m.h).(mergeEntry)
+	return e.r, m.push(e.iter)
+}
+
+type mergeHeap []mergeEntry
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	cmp := bytes.Compare(h[i].r.key, h[j].r.key)
+	if cmp == 0 {
+		return h[i].iter < h[j].iter
+	}
+	return cmp < 0
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeEntry)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,196 @@
+package sstable
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func buildSorted(t *testing.T, opts SortingBuilderOptions, add func(b *SortingBuilder)) (*Table, error) {
+	buf := new(bytes.Buffer)
+	b := NewSortingBuilder(buf, opts)
+	add(b)
+	if err := b.Build(); err != nil {
+		return nil, err
+	}
+	return Load(bytes.NewReader(buf.Bytes()))
+}
+
+func TestSortingBuilder(t *testing.T) {
+	entries := make(map[string]testValuePair)
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%04d", i)
+		val := strings.Repeat("v", i%13)
+		if i%3 == 0 {
+			// Some identical values, for deduplication.
+			val = "same"
+		}
+		entries[key] = testValuePair{val, []byte(key[3:])}
+		keys = append(keys, key)
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+
+	for _, opts := range []SortingBuilderOptions{
+		{},
+		{MemoryLimit: 4096},
+		{MemoryLimit: 4096, BuilderOptions: BuilderOptions{Dedup: true}},
+	} {
+		opts.TempDir = t.TempDir()
+		table, err := buildSorted(t, opts, func(b *SortingBuilder) {
+			for _, k := range keys {
+				if err := b.Add([]byte(k), []byte(entries[k].val), entries[k].extra); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.NumKeys() != len(entries) {
+			t.Error("Unexpected num keys", table.NumKeys())
+		}
+		checkTable(t, table, entries)
+
+		files, err := os.ReadDir(opts.TempDir)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(files) > 0 {
+			t.Error("Temporary files not removed", files)
+		}
+	}
+}
+
+func TestSortingBuilder_Duplicates(t *testing.T) {
+	add := func(b *SortingBuilder) {
+		for i := 0; i < 100; i++ {
+			b.Add([]byte(fmt.Sprintf("key%02d", i%10)), []byte(fmt.Sprintf("value%02d", i)), nil)
+		}
+	}
+	for _, limit := range []int{0, 256} {
+		_, err := buildSorted(t, SortingBuilderOptions{MemoryLimit: limit}, add)
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Error("Expected ErrDuplicateKey, got", err)
+		}
+
+		for _, c := range []struct {
+			policy   DuplicatePolicy
+			expected string
+		}{
+			{DuplicateKeepFirst, "value03"},
+			{DuplicateKeepLast, "value93"},
+		} {
+			table, err := buildSorted(t, SortingBuilderOptions{MemoryLimit: limit, Duplicates: c.policy}, add)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if table.NumKeys() != 10 {
+				t.Error("Unexpected num keys", table.NumKeys())
+			}
+			if v, _, _ := table.Get([]byte("key03")); string(v) != c.expected {
+				t.Errorf("Unexpected value %q, expected %q", v, c.expected)
+			}
+		}
+	}
+}
+
+func TestSortingBuilder_KeyTooLong(t *testing.T) {
+	b := NewSortingBuilder(new(bytes.Buffer), SortingBuilderOptions{})
+	defer b.Abort()
+	if err := b.Add(make([]byte, MaxKeyLength+1), nil, nil); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestSortingBuilder_MergePasses(t *testing.T) {
+	for _, policy := range []DuplicatePolicy{DuplicateKeepFirst, DuplicateKeepLast} {
+		dir := t.TempDir()
+		buf := new(bytes.Buffer)
+		b := NewSortingBuilder(buf, SortingBuilderOptions{
+			MemoryLimit:    512,
+			TempDir:        dir,
+			Duplicates:     policy,
+			BuilderOptions: BuilderOptions{Dedup: true},
+		})
+		// Merge at most 3 runs at once, so runs are merged in several passes.
+		b.sorter.fanIn = 3
+		for i := 999; i >= 0; i-- {
+			key := []byte(fmt.Sprintf("key%03d", i%300))
+			if err := b.Add(key, []byte(fmt.Sprintf("value%04d", i)), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(b.sorter.runs) < 10 {
+			t.Error("Expected many runs", len(b.sorter.runs))
+		}
+		if err := b.Build(); err != nil {
+			t.Fatal(err)
+		}
+
+		table, err := buildReader(t, buf.Bytes())
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		} else if table.NumKeys() != 300 {
+			t.Error("Unexpected num keys", table.NumKeys())
+		}
+		// key042 is added with values 942, 642, 342 and 042, in that order.
+		expected := "value0942"
+		if policy == DuplicateKeepLast {
+			expected = "value0042"
+		}
+		if v, _, _ := table.Get([]byte("key042")); string(v) != expected {
+			t.Errorf("Unexpected value %q, expected %q", v, expected)
+		}
+		if files, _ := os.ReadDir(dir); len(files) > 0 {
+			t.Error("Temporary files not removed", files)
+		}
+	}
+}
+
+func TestSortingBuilder_InvalidOptions(t *testing.T) {
+	b := NewSortingBuilder(new(bytes.Buffer), SortingBuilderOptions{
+		BuilderOptions: BuilderOptions{MaxKeyLength: MaxKeyLengthLimit + 1},
+	})
+	if err := b.Add([]byte("key"), nil, nil); err == nil {
+		t.Error("Expected error from Add")
+	}
+	if err := b.Build(); err == nil {
+		t.Error("Expected error from Build")
+	}
+}
+
+func TestSortingBuilder_Done(t *testing.T) {
+	dir := t.TempDir()
+	b := NewSortingBuilder(new(bytes.Buffer), SortingBuilderOptions{MemoryLimit: 512, TempDir: dir})
+	for i := 0; i < 100; i++ {
+		if err := b.Add([]byte(fmt.Sprintf("key%03d", i)), []byte("value"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Abort()
+	for i := 100; i < 200; i++ {
+		if err := b.Add([]byte(fmt.Sprintf("key%03d", i)), []byte("value"), nil); err != ErrBuilderDone {
+			t.Fatal("Expected ErrBuilderDone, got", err)
+		}
+	}
+	if err := b.Build(); err != ErrBuilderDone {
+		t.Error("Expected ErrBuilderDone, got", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) > 0 {
+		t.Error("Temporary files not removed", files)
+	}
+
+	b = NewSortingBuilder(new(bytes.Buffer), SortingBuilderOptions{TempDir: dir})
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add([]byte("key"), nil, nil); err != ErrBuilderDone {
+		t.Error("Expected ErrBuilderDone, got", err)
+	}
+}
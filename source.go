@@ -0,0 +1,185 @@
+package sstable
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SourceEntry is a table entry produced by a Source.
+type SourceEntry struct {
+	Key   []byte
+	Value []byte
+	Extra []byte
+}
+
+// Source produces entries for BuildFrom and BuildFromOpener, in strictly
+// increasing key order.
+type Source interface {
+	// Returns the next entry, or io.EOF when there are no more entries. The
+	// entry's data only needs to remain valid until the next call.
+	Next() (SourceEntry, error)
+}
+
+type chanSource struct {
+	ch <-chan SourceEntry
+}
+
+// ChanSource returns a Source which reads entries from ch until it is closed.
+func ChanSource(ch <-chan SourceEntry) Source {
+	return &chanSource{ch: ch}
+}
+
+func (s *chanSource) Next() (SourceEntry, error) {
+	e, ok := <-s.ch
+	if !ok {
+		return e, io.EOF
+	}
+	return e, nil
+}
+
+// SourceOpener opens a Source, positioned at its first entry. Each Source it
+// returns must produce the same entries. If a Source implements io.Closer, it
+// is closed when no longer needed.
+type SourceOpener func() (Source, error)
+
+type BuildFromOptions struct {
+	BuilderOptions
+
+	// Directory for the temporary file BuildFrom spools values to. If empty,
+	// the default directory for temporary files is used (see os.TempDir).
+	TempDir string
+}
+
+// BuildFrom builds a table from the entries produced by src, reading src once.
+// A table's index, which contains every key, precedes the values, and its size
+// isn't known until every key has been read. So the values can't be written
+// as they are read, and are instead spooled to a temporary file until the
+// index is written. If the source can be read twice, BuildFromOpener avoids
+// spooling values.
+func BuildFrom(w io.Writer, src Source) error {
+	return BuildFromWithOptions(w, src, BuildFromOptions{})
+}
+
+func BuildFromWithOptions(w io.Writer, src Source, opts BuildFromOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	spool, err := newRunWriter(opts.TempDir)
+	if err != nil {
+		return err
+	}
+	open := func() (recordIter, io.Closer, error) {
+		f, err := os.Open(spool.f.Name())
+		if err != nil {
+			return nil, nil, err
+		}
+		return &runIter{r: bufio.NewReader(f)}, f, nil
+	}
+	values := &valueCursor{open: open}
+	defer func() {
+		values.close()
+		spool.abort()
+	}()
+
+	b := NewBuilderWithOptions(w, values.write, opts.BuilderOptions)
+	err = addSourceKeys(b, src, func(e SourceEntry) error {
+		if len(e.Value) == 0 {
+			// Empty values are never requested, so aren't spooled.
+			return nil
+		}
+		return spool.write(record{key: e.Key, value: e.Value})
+	})
+	if err != nil {
+		return err
+	}
+	if err := spool.w.Flush(); err != nil {
+		return err
+	}
+	return b.Build()
+}
+
+// BuildFromOpener builds a table from the entries produced by sources from
+// open, without spooling values (see BuildFrom). One source is read for the
+// keys, and another for the values, which are written directly to w. When
+// deduplicating values, sources are also opened to hash the values, and when
+// a value is repeated.
+func BuildFromOpener(w io.Writer, open SourceOpener, opts BuildFromOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	values := &valueCursor{open: func() (recordIter, io.Closer, error) {
+		src, err := open()
+		if err != nil {
+			return nil, nil, err
+		}
+		return &sourceIter{src: src}, sourceCloser(src), nil
+	}}
+	defer values.close()
+
+	src, err := open()
+	if err != nil {
+		return err
+	}
+	b := NewBuilderWithOptions(w, values.write, opts.BuilderOptions)
+	err = addSourceKeys(b, src, nil)
+	if cerr := sourceCloser(src).Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return b.Build()
+}
+
+// Adds the entries of src to b, checking they are sorted, and calling fn (if
+// not nil) with each entry.
+func addSourceKeys(b *Builder, src Source, fn func(e SourceEntry) error) error {
+	var prev []byte
+	for i := 0; ; i++ {
+		e, err := src.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if len(e.Key) > b.maxKeyLength {
+			return fmt.Errorf("Key %q length %d > %d", e.Key, len(e.Key), b.maxKeyLength)
+		} else if i > 0 && bytes.Compare(prev, e.Key) >= 0 {
+			return fmt.Errorf("Key %q is not after previous key %q", e.Key, prev)
+		}
+		prev = append(prev[:0], e.Key...)
+
+		if fn != nil {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		if err := b.Add(e.Key, uint64(len(e.Value)), e.Extra); err != nil {
+			return err
+		}
+	}
+}
+
+// Reads the keys and values of a Source.
+type sourceIter struct {
+	src Source
+}
+
+func (i *sourceIter) next() (record, error) {
+	e, err := i.src.Next()
+	if err != nil {
+		return record{}, err
+	}
+	return record{key: e.Key, value: e.Value, valueLen: len(e.Value)}, nil
+}
+
+func sourceCloser(src Source) io.Closer {
+	if c, ok := src.(io.Closer); ok {
+		return c
+	}
+	return nopCloser{}
+}
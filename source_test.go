@@ -0,0 +1,178 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"testing"
+)
+
+type sliceSource struct {
+	entries []SourceEntry
+}
+
+func (s *sliceSource) Next() (SourceEntry, error) {
+	if len(s.entries) == 0 {
+		return SourceEntry{}, io.EOF
+	}
+	e := s.entries[0]
+	s.entries = s.entries[1:]
+	return e, nil
+}
+
+// Returns a SourceOpener for entries, counting the number of sources opened.
+func sliceOpener(entries []SourceEntry, opened *int) SourceOpener {
+	return func() (Source, error) {
+		if opened != nil {
+			*opened++
+		}
+		return &sliceSource{entries}, nil
+	}
+}
+
+func sourceEntries(entries map[string]testValuePair) []SourceEntry {
+	var sorted []SourceEntry
+	for k, p := range entries {
+		sorted = append(sorted, SourceEntry{Key: []byte(k), Value: []byte(p.val), Extra: p.extra})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Key, sorted[j].Key) < 0
+	})
+	return sorted
+}
+
+func TestBuildFromOpener(t *testing.T) {
+	for _, opts := range []BuildFromOptions{
+		{},
+		{BuilderOptions: BuilderOptions{Dedup: true, Checksums: true}},
+	} {
+		opts.TempDir = t.TempDir()
+		buf := new(bytes.Buffer)
+		opened := 0
+		err := BuildFromOpener(buf, sliceOpener(sourceEntries(testValues), &opened), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, err := buildReader(t, buf.Bytes())
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		}
+		checkTable(t, table, testValues)
+
+		if opened < 2 {
+			t.Errorf("Source opened %d times, expected at least 2", opened)
+		}
+		if files, _ := os.ReadDir(opts.TempDir); len(files) > 0 {
+			t.Error("Temporary files created", files)
+		}
+	}
+}
+
+func TestBuildFromOpener_Dedup(t *testing.T) {
+	entries := generateEntries(100)
+	buf := new(bytes.Buffer)
+	opts := BuildFromOptions{BuilderOptions: BuilderOptions{Dedup: true}}
+	if err := BuildFromOpener(buf, sliceOpener(sourceEntries(entries), nil), opts); err != nil {
+		t.Fatal(err)
+	}
+	table, err := buildReader(t, buf.Bytes())
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	checkTable(t, table, entries)
+}
+
+func TestBuildFromOpener_OpenError(t *testing.T) {
+	openErr := fmt.Errorf("Open failed")
+	opened := 0
+	open := func() (Source, error) {
+		opened++
+		if opened > 1 {
+			return nil, openErr
+		}
+		return &sliceSource{sourceEntries(testValues)}, nil
+	}
+	if err := BuildFromOpener(io.Discard, open, BuildFromOptions{}); err != openErr {
+		t.Error("Expected open error, got", err)
+	}
+}
+
+func TestBuildFrom(t *testing.T) {
+	opts := BuildFromOptions{
+		BuilderOptions: BuilderOptions{Dedup: true, Checksums: true},
+		TempDir:        t.TempDir(),
+	}
+	buf := new(bytes.Buffer)
+	if err := BuildFromWithOptions(buf, &sliceSource{sourceEntries(testValues)}, opts); err != nil {
+		t.Fatal(err)
+	}
+	table, err := buildReader(t, buf.Bytes())
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	checkTable(t, table, testValues)
+
+	if files, _ := os.ReadDir(opts.TempDir); len(files) > 0 {
+		t.Error("Temporary files not removed", files)
+	}
+}
+
+func TestBuildFrom_Chan(t *testing.T) {
+	entries := generateEntries(100)
+	ch := make(chan SourceEntry)
+	go func() {
+		for _, e := range sourceEntries(entries) {
+			ch <- e
+		}
+		close(ch)
+	}()
+
+	buf := new(bytes.Buffer)
+	if err := BuildFrom(buf, ChanSource(ch)); err != nil {
+		t.Fatal(err)
+	}
+	table, err := buildReader(t, buf.Bytes())
+	if err != nil {
+		t.Fatal("Error loading table", err)
+	}
+	checkTable(t, table, entries)
+}
+
+func TestBuildFromOpener_Unsorted(t *testing.T) {
+	entries := sourceEntries(testValues)
+	entries[2], entries[3] = entries[3], entries[2]
+	if err := BuildFromOpener(io.Discard, sliceOpener(entries, nil), BuildFromOptions{}); err == nil {
+		t.Error("Expected error")
+	}
+
+	entries = []SourceEntry{{Key: []byte("a")}, {Key: []byte("a")}}
+	if err := BuildFromOpener(io.Discard, sliceOpener(entries, nil), BuildFromOptions{}); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestBuildFrom_SourceError(t *testing.T) {
+	ch := make(chan SourceEntry, 1)
+	ch <- SourceEntry{Key: []byte("a"), Value: []byte("1")}
+	close(ch)
+	errSource := &errorSource{Source: ChanSource(ch), err: fmt.Errorf("Source failed")}
+	if err := BuildFrom(io.Discard, errSource); err != errSource.err {
+		t.Error("Expected source error, got", err)
+	}
+}
+
+// Returns err after the wrapped source is exhausted.
+type errorSource struct {
+	Source
+	err error
+}
+
+func (s *errorSource) Next() (SourceEntry, error) {
+	e, err := s.Source.Next()
+	if err == io.EOF {
+		err = s.err
+	}
+	return e, err
+}
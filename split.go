@@ -0,0 +1,124 @@
+package sstable
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+type SplitOptions struct {
+	// Keys at which new tables start. Keys before Boundaries[0] are written to
+	// the first table, keys in [Boundaries[0], Boundaries[1]) to the second, and
+	// so on. Must be sorted.
+	Boundaries [][]byte
+
+	// If Boundaries is empty, split into tables with approximately this many
+	// bytes of keys and values each.
+	TargetSize uint64
+
+	// Options for building new tables. If MaxKeyLength is 0, the source table's
+	// maximum key length is used.
+	BuilderOptions BuilderOptions
+}
+
+// Returns the writer for the i'th new table, for Split and RotatingBuilder. If
+// the writer implements io.Closer, it is closed after the table is built.
+type SplitWriterFactory func(i int) (io.Writer, error)
+
+// Describes a table created by Split or RotatingBuilder.
+type SplitTable struct {
+	FirstKey []byte
+	LastKey  []byte
+	NumKeys  int
+}
+
+var errBoundariesNotSorted = errors.New("Split boundaries not sorted")
+
+// Split copies the entries of t into new tables, split by key range. Ranges
+// with no keys do not produce a table. If t is a key set, the new tables are
+// also key sets, and only the MaxKeyLength, IndexRestartInterval and
+// KeyProvider builder options are used.
+func Split(t *Table, opts SplitOptions, factory SplitWriterFactory) ([]SplitTable, error) {
+	for i := 1; i < len(opts.Boundaries); i++ {
+		if bytes.Compare(opts.Boundaries[i-1], opts.Boundaries[i]) > 0 {
+			return nil, errBoundariesNotSorted
+		}
+	}
+	boundaries := opts.Boundaries
+	if len(boundaries) == 0 && opts.TargetSize > 0 {
+		total := t.ApproximateSize(nil, nil)
+		boundaries = t.SplitPoints(int((total + opts.TargetSize - 1) / opts.TargetSize))
+	}
+	builderOpts := opts.BuilderOptions
+	if builderOpts.MaxKeyLength == 0 {
+		builderOpts.MaxKeyLength = t.maxKeyLength
+	}
+
+	var tables []SplitTable
+	start := 0
+	for b := 0; b <= len(boundaries); b++ {
+		end := t.index.len()
+		if b < len(boundaries) {
+			end = t.index.search(boundaries[b])
+		}
+		if end <= start {
+			continue
+		}
+
+		w, err := factory(len(tables))
+		if err != nil {
+			return tables, err
+		}
+		err = t.copyEntries(w, start, end, builderOpts)
+		if c, ok := w.(io.Closer); ok {
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			return tables, err
+		}
+		tables = append(tables, SplitTable{
+			FirstKey: t.index.key(start),
+			LastKey:  t.index.key(end - 1),
+			NumKeys:  end - start,
+		})
+		start = end
+	}
+	return tables, nil
+}
+
+// Builds a new table from entries [start, end).
+func (t *Table) copyEntries(w io.Writer, start, end int, opts BuilderOptions) error {
+	if t.keySet {
+		b := NewSetBuilderWithOptions(w, SetBuilderOptions{
+			MaxKeyLength:         opts.MaxKeyLength,
+			IndexRestartInterval: opts.IndexRestartInterval,
+			KeyProvider:          opts.KeyProvider,
+		})
+		for i := start; i < end; i++ {
+			if err := b.Add(t.index.key(i)); err != nil {
+				return err
+			}
+		}
+		return b.Build()
+	}
+
+	vf := func(key []byte, w io.Writer) (int, error) {
+		i, ok := t.index.find(key)
+		if !ok {
+			return 0, ErrNotFound
+		}
+		r := t.valueReader(i)
+		n, err := io.Copy(w, io.NewSectionReader(r, 0, r.Size()))
+		return int(n), err
+	}
+
+	b := NewBuilderWithOptions(w, vf, opts)
+	for i := start; i < end; i++ {
+		if err := b.Add(t.index.key(i), t.index.length(i), t.index.extra(i)); err != nil {
+			return err
+		}
+	}
+	return b.Build()
+}
@@ -0,0 +1,139 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func checkSplit(t *testing.T, bufs []*bytes.Buffer, tables []SplitTable, entries map[string]testValuePair) {
+	if len(bufs) != len(tables) {
+		t.Fatal("Unexpected number of tables", len(bufs), len(tables))
+	}
+	total := 0
+	for i, buf := range bufs {
+		table, err := buildReader(t, buf.Bytes())
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		}
+		total += table.NumKeys()
+		if table.NumKeys() != tables[i].NumKeys {
+			t.Error("Unexpected number of keys", table.NumKeys(), tables[i].NumKeys)
+		}
+		keys := table.Keys()
+		if !bytes.Equal(keys[0], tables[i].FirstKey) || !bytes.Equal(keys[len(keys)-1], tables[i].LastKey) {
+			t.Error("Unexpected key range", tables[i])
+		}
+		for _, k := range keys {
+			v, e, err := table.Get(k)
+			p := entries[string(k)]
+			if err != nil || string(v) != p.val || !bytes.Equal(e, p.extra) {
+				t.Error("Unexpected entry", string(k), v, e, err)
+			}
+		}
+	}
+	if total != len(entries) {
+		t.Error("Unexpected total number of keys", total)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	entries := make(map[string]testValuePair)
+	for i := 0; i < 100; i++ {
+		val := fmt.Sprintf("v%03d", i)
+		if i%10 == 0 {
+			val = ""
+		}
+		entries[fmt.Sprintf("key%03d", i)] = testValuePair{val, []byte{byte(i)}}
+	}
+	table, err := buildReader(t, buildTable(t, entries))
+	if err != nil {
+		t.Fatal("Error building table", err)
+	}
+
+	var bufs []*bytes.Buffer
+	factory := func(i int) (io.Writer, error) {
+		if i != len(bufs) {
+			t.Error("Unexpected table index", i)
+		}
+		bufs = append(bufs, new(bytes.Buffer))
+		return bufs[i], nil
+	}
+
+	tables, err := Split(table, SplitOptions{
+		Boundaries: [][]byte{[]byte("a"), []byte("key050"), []byte("key0505"), []byte("key090")},
+	}, factory)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	checkSplit(t, bufs, tables, entries)
+	// No keys before "a", so no table for that range.
+	if len(tables) != 4 || tables[1].NumKeys != 1 || string(tables[2].FirstKey) != "key051" {
+		t.Error("Unexpected tables", tables)
+	}
+
+	bufs = nil
+	tables, err = Split(table, SplitOptions{TargetSize: 250}, factory)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	checkSplit(t, bufs, tables, entries)
+	if len(tables) != 4 {
+		t.Error("Unexpected tables", tables)
+	}
+}
+
+func TestSplit_Unsorted(t *testing.T) {
+	table, err := buildReader(t, buildTable(t, testValues))
+	if err != nil {
+		t.Fatal("Error building table", err)
+	}
+	created := 0
+	_, err = Split(table, SplitOptions{
+		Boundaries: [][]byte{[]byte("foo2"), []byte("foo1")},
+	}, func(i int) (io.Writer, error) {
+		created++
+		return io.Discard, nil
+	})
+	if err != errBoundariesNotSorted {
+		t.Error("Expected errBoundariesNotSorted, got", err)
+	} else if created != 0 {
+		t.Error("Unexpected tables created", created)
+	}
+}
+
+func TestSplit_KeySet(t *testing.T) {
+	keys := setKeys(100, 1)
+	table, err := buildReader(t, buildSet(t, keys, SetBuilderOptions{}))
+	if err != nil {
+		t.Fatal("Error loading set", err)
+	}
+
+	var bufs []*bytes.Buffer
+	tables, err := Split(table, SplitOptions{
+		Boundaries:     [][]byte{[]byte("key0050")},
+		BuilderOptions: BuilderOptions{Checksums: true},
+	}, func(i int) (io.Writer, error) {
+		bufs = append(bufs, new(bytes.Buffer))
+		return bufs[i], nil
+	})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	} else if len(tables) != 2 {
+		t.Fatal("Unexpected tables", tables)
+	}
+	var got []string
+	for _, buf := range bufs {
+		st, err := buildReader(t, buf.Bytes())
+		if err != nil {
+			t.Fatal("Error loading table", err)
+		} else if !st.IsKeySet() {
+			t.Error("Split table not a key set")
+		}
+		got = append(got, tableKeys(st)...)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(keys) {
+		t.Error("Unexpected keys", got)
+	}
+}
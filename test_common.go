@@ -2,8 +2,10 @@ package sstable
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -32,14 +34,46 @@ var testValuesKeyTooLong = map[string]testValuePair{
 
 var emptyTable = map[string]testValuePair{}
 
+// Returns n entries, with keys "key0000", "key0001", etc. Entry i has extra
+// data byte(i), and a value of i%10 * 37 bytes, with the byte depending only on
+// i%10. So every 10th value is empty, and there are 9 distinct non-empty values
+// of up to 333 bytes.
+func generateEntries(n int) map[string]testValuePair {
+	entries := make(map[string]testValuePair, n)
+	for i := 0; i < n; i++ {
+		j := i % 10
+		val := strings.Repeat(string(rune('a'+j)), j*37)
+		entries[fmt.Sprintf("key%04d", i)] = testValuePair{val, []byte{byte(i)}}
+	}
+	return entries
+}
+
 func buildTable(t *testing.T, entries map[string]testValuePair) []byte {
+	return buildTableWithOptions(t, entries, BuilderOptions{})
+}
+
+func buildTableWithOptions(t *testing.T, entries map[string]testValuePair, opts BuilderOptions) []byte {
 	w := new(bytes.Buffer)
+	b := NewBuilderWithOptions(w, testValueWriter(entries), opts)
+	addTestEntries(t, b, entries)
+
+	err := b.Build()
+	if err != nil {
+		t.Error(err)
+	}
+
+	return w.Bytes()
+}
 
-	vf := func(key []byte, w io.Writer) (int, error) {
+// Returns a ValueWriter for the values in entries.
+func testValueWriter(entries map[string]testValuePair) ValueWriter {
+	return func(key []byte, w io.Writer) (int, error) {
 		return w.Write([]byte(entries[string(key)].val))
 	}
-	b := NewBuilder(w, vf)
+}
 
+// Adds entries to b, in key order.
+func addTestEntries(t *testing.T, b *Builder, entries map[string]testValuePair) {
 	var sortedKeys []string
 	for k, _ := range entries {
 		sortedKeys = append(sortedKeys, string(k))
@@ -47,15 +81,10 @@ func buildTable(t *testing.T, entries map[string]testValuePair) []byte {
 	sort.Strings(sortedKeys)
 
 	for _, k := range sortedKeys {
-		b.Add([]byte(k), uint32(len(entries[k].val)), entries[k].extra)
-	}
-
-	err := b.Build()
-	if err != nil {
-		t.Error(err)
+		if err := b.Add([]byte(k), uint64(len(entries[k].val)), entries[k].extra); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
 	}
-
-	return w.Bytes()
 }
 
 func buildReader(t *testing.T, buf []byte) (*Table, error) {
@@ -104,7 +133,7 @@ func checkTable(t *testing.T, table *Table, entries map[string]testValuePair) {
 		if !bytes.Equal(p.extra, e) {
 			t.Error("Incorrect extra", p.extra, e)
 		}
-		if uint(len(p.val)) != n {
+		if uint64(len(p.val)) != n {
 			t.Error("Incorrect value length", len(p.val), n)
 		}
 
@@ -115,7 +144,7 @@ func checkTable(t *testing.T, table *Table, entries map[string]testValuePair) {
 		if !bytes.Equal(p.extra, e) {
 			t.Error("Incorrect extra", p.extra, e)
 		}
-		if uint(len(p.val)) != n {
+		if uint64(len(p.val)) != n {
 			t.Error("Incorrect value length", len(p.val), n)
 		}
 	}
@@ -136,7 +165,7 @@ func checkPrev(t *testing.T, table *Table, entries map[string]testValuePair, key
 		t.Error("Incorrect extra", expectedExtra, e)
 	}
 	expectedVal := entries[expected].val
-	if uint(len(expectedVal)) != n {
+	if uint64(len(expectedVal)) != n {
 		t.Error("Incorrect value length", len(expectedVal), n)
 	}
 }
@@ -156,7 +185,7 @@ func checkNext(t *testing.T, table *Table, entries map[string]testValuePair, key
 		t.Error("Incorrect extra", expectedExtra, e)
 	}
 	expectedVal := entries[expected].val
-	if uint(len(expectedVal)) != n {
+	if uint64(len(expectedVal)) != n {
 		t.Error("Incorrect value length", len(expectedVal), n)
 	}
 }
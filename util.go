@@ -1,7 +1,26 @@
 package sstable
 
+import (
+	"hash/crc32"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
 func dup(b []byte) []byte {
 	r := make([]byte, len(b))
 	copy(r, b)
 	return r
 }
+
+func commonPrefix(a, b []byte) int {
+	maxLen := len(a)
+	if len(b) < maxLen {
+		maxLen = len(b)
+	}
+	for i := 0; i < maxLen; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return maxLen
+}
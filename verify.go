@@ -0,0 +1,205 @@
+package sstable
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// Maximum number of problems recorded in a VerifyReport.
+const maxVerifyProblems = 1000
+
+type VerifyProblem struct {
+	// Key of the entry with the problem, or nil for table-level problems.
+	Key     []byte
+	Message string
+}
+
+func (p VerifyProblem) String() string {
+	if p.Key == nil {
+		return p.Message
+	}
+	return fmt.Sprintf("Key %q: %s", p.Key, p.Message)
+}
+
+type VerifyReport struct {
+	NumKeys int
+
+	// Number of values whose checksums were verified.
+	ChecksumsVerified int
+
+	// Number of entries sharing a value with an earlier entry, in deduplicated
+	// tables.
+	SharedValues int
+
+	// Number of encrypted values which were successfully decrypted (and
+	// therefore authenticated).
+	ValuesDecrypted int
+
+	// Total number of problems found. Only the first 1000 are recorded in
+	// Problems.
+	NumProblems int
+	Problems    []VerifyProblem
+}
+
+func (r *VerifyReport) OK() bool {
+	return r.NumProblems == 0
+}
+
+func (r *VerifyReport) addProblem(key []byte, format string, args ...interface{}) {
+	r.NumProblems++
+	if len(r.Problems) < maxVerifyProblems {
+		r.Problems = append(r.Problems, VerifyProblem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// Verify checks the table for consistency. It checks that values are laid out
+// contiguously within the data section (entries in deduplicated tables may
+// also share an earlier value, and aligned values may be preceded by
+// padding), that the file has the expected length, and if the table has
+// checksums or is encrypted, reads every value to verify its checksum or
+// authentication tags.
+// Problems with the table are returned in the report. An error is only
+// returned if verification could not complete (i.e. ctx is done).
+func (t *Table) Verify(ctx context.Context) (*VerifyReport, error) {
+	report := &VerifyReport{NumKeys: t.index.len()}
+
+	var pos, padding uint64
+	// Entries which don't share an earlier value, in offset order.
+	var distinct []int
+	for i := 0; i < t.index.len(); i++ {
+		key := t.index.key(i)
+		offset, length := t.index.offset(i), t.storedLength(i)
+		if offset < pos {
+			if length > 0 && t.isSharedValue(distinct, offset, length) {
+				report.SharedValues++
+				continue
+			}
+			report.addProblem(key, "value at offset %d overlaps previous value ending at %d", offset, pos)
+		} else {
+			if offset > pos && t.isPadding(pos, offset) {
+				padding += offset - pos
+			} else if offset > pos {
+				report.addProblem(key, "gap of %d bytes before value at offset %d", offset-pos, offset)
+			}
+			distinct = append(distinct, i)
+		}
+		if end := offset + length; end > pos {
+			pos = end
+		}
+	}
+
+	if padding != t.dataPadding {
+		report.addProblem(nil, "%d bytes of padding, expected %d", padding, t.dataPadding)
+	}
+
+	dataLength := t.dataLength
+	if t.version == 1 {
+		// Version 1 tables don't record the data length.
+		dataLength = pos
+	} else if pos != dataLength {
+		report.addProblem(nil, "values end at %d, expected data length %d", pos, dataLength)
+	}
+
+	fileLength := t.dataOffset + dataLength
+	if t.checksums != nil {
+		fileLength += uint64(4 * len(t.checksums))
+	}
+	if err := t.verifyLength(ctx, int64(fileLength), report); err != nil {
+		return nil, err
+	}
+
+	if t.checksums != nil || t.cipher != nil {
+		if err := t.verifyValues(ctx, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// Returns true if the gap between data offsets [start, end) is alignment
+// padding.
+func (t *Table) isPadding(start, end uint64) bool {
+	return t.alignment > 0 && end-start < t.alignment && (t.dataOffset+end)%t.alignment == 0
+}
+
+// Returns true if one of the distinct entries has a value at offset with the
+// given length.
+func (t *Table) isSharedValue(distinct []int, offset, length uint64) bool {
+	j := sort.Search(len(distinct), func(j int) bool {
+		return t.index.offset(distinct[j]) >= offset
+	})
+	for ; j < len(distinct) && t.index.offset(distinct[j]) == offset; j++ {
+		if t.storedLength(distinct[j]) == length {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks the file is exactly the given length.
+func (t *Table) verifyLength(ctx context.Context, length int64, report *VerifyReport) error {
+	var b [1]byte
+	if length > 0 {
+		_, err := readAtContext(ctx, t.r, b[:], length-1)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		} else if err == io.EOF {
+			report.addProblem(nil, "file truncated, expected length %d", length)
+			return nil
+		} else if err != nil {
+			report.addProblem(nil, "error reading end of file: %v", err)
+			return nil
+		}
+	}
+
+	n, err := readAtContext(ctx, t.r, b[:], length)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	} else if n > 0 {
+		report.addProblem(nil, "unexpected data after end of table at %d", length)
+	} else if err != io.EOF {
+		report.addProblem(nil, "error reading end of file: %v", err)
+	}
+	return nil
+}
+
+// Size of reads when verifying values.
+const verifyReadSize = 1024 * 1024
+
+// Reads every value, checking its checksum. Encrypted values are
+// authenticated as they are decrypted.
+func (t *Table) verifyValues(ctx context.Context, report *VerifyReport) error {
+	buf := make([]byte, verifyReadSize)
+	for i := 0; i < t.index.len(); i++ {
+		r := t.valueReader(i)
+		var crc uint32
+		var readErr error
+		for off := int64(0); off < r.Size(); off += int64(len(buf)) {
+			n, err := r.ReadAtContext(ctx, buf, off)
+			crc = crc32.Update(crc, crc32cTable, buf[:n])
+			if err == io.EOF && off+int64(n) == r.Size() {
+				err = nil
+			}
+			if err != nil {
+				readErr = err
+				break
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		} else if readErr != nil {
+			report.addProblem(t.index.key(i), "error reading value: %v", readErr)
+		} else if t.checksums == nil {
+			report.ValuesDecrypted++
+		} else if crc != t.checksums[i] {
+			report.addProblem(t.index.key(i), "checksum mismatch, expected %08x, actual %08x", t.checksums[i], crc)
+		} else {
+			report.ChecksumsVerified++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package sstable
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/akmistry/simple-sstable/proto"
+)
+
+func verifyTable(t *testing.T, buf []byte) *VerifyReport {
+	table, err := buildReader(t, buf)
+	if err != nil {
+		t.Fatal("Error building table", err)
+	}
+	report, err := table.Verify(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	for _, p := range report.Problems {
+		t.Log("Problem:", p)
+	}
+	return report
+}
+
+func TestVerify(t *testing.T) {
+	buf := buildTableWithOptions(t, testValues, BuilderOptions{Checksums: true})
+	report := verifyTable(t, buf)
+	if !report.OK() {
+		t.Error("Unexpected problems", report.Problems)
+	}
+	if report.NumKeys != len(testValues) || report.ChecksumsVerified != len(testValues) {
+		t.Error("Unexpected report", report)
+	}
+
+	report = verifyTable(t, buildTable(t, emptyTable))
+	if !report.OK() {
+		t.Error("Unexpected problems", report.Problems)
+	}
+}
+
+func TestVerify_Corrupt(t *testing.T) {
+	buf := buildTableWithOptions(t, testValues, BuilderOptions{Checksums: true})
+	i := bytes.Index(buf, []byte("randomstuff"))
+	buf[i] = 'R'
+	report := verifyTable(t, buf)
+	if report.NumProblems != 1 || string(report.Problems[0].Key) != "hoo" {
+		t.Error("Unexpected problems", report.Problems)
+	}
+}
+
+func TestVerify_Length(t *testing.T) {
+	buf := buildTable(t, testValues)
+	report := verifyTable(t, buf[:len(buf)-1])
+	if report.NumProblems != 1 || report.Problems[0].Key != nil {
+		t.Error("Unexpected problems", report.Problems)
+	}
+
+	report = verifyTable(t, append(buf, 0))
+	if report.NumProblems != 1 || report.Problems[0].Key != nil {
+		t.Error("Unexpected problems", report.Problems)
+	}
+}
+
+func TestVerify_DataLength(t *testing.T) {
+	buf := buildTable(t, testValues)
+	hs := binary.LittleEndian.Uint32(buf)
+	var header pb.TableHeader
+	if err := proto.Unmarshal(buf[4:4+hs], &header); err != nil {
+		t.Fatal(err)
+	} else if header.Version != 2 {
+		t.Fatal("Unexpected version", header.Version)
+	}
+
+	// Rewrite the header with a zero data length.
+	header.DataLength = 0
+	headerBuf, err := proto.Marshal(&header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := binary.LittleEndian.AppendUint32(nil, uint32(len(headerBuf)))
+	corrupt = append(corrupt, headerBuf...)
+	corrupt = append(corrupt, buf[4+hs:]...)
+	report := verifyTable(t, corrupt)
+	if report.OK() {
+		t.Error("Expected problems")
+	}
+}